@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// checkLoop reads the managed records every check interval and
+// only runs CheckAndUpdateDNS when they drifted from the desired state, e.g.
+// after a manual change at the provider. The checks themselves never write.
+func (s *Sentinel) checkLoop(ctx context.Context) {
+	timer := time.NewTimer(s.jitter(s.Config.CheckInterval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			timer.Reset(s.jitter(s.Config.CheckInterval))
+
+			drifted, err := s.recordsDrifted(ctx)
+			if err != nil {
+				logger.Warnf("DNS check failed: %v", err)
+				continue
+			}
+			if drifted {
+				logger.Infof("DNS records drifted from the desired state, updating")
+				_ = s.CheckAndUpdateDNS(ctx)
+			}
+		}
+	}
+}
+
+// recordsDrifted compares the records at the provider with the records this
+// instance published on its last update, without writing anything
+func (s *Sentinel) recordsDrifted(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	multi := s.Config.Mode == ModeMulti
+	if !s.wasLeader && !(multi && len(s.published) > 0) {
+		logger.Debugf("Not publishing any records, skipping DNS check")
+		return false, nil
+	}
+
+	for _, z := range s.zones() {
+		zone := z.zone()
+
+		// Out-of-band changes are only visible in fresh records
+		records, err := s.getRecords(ctx, zone, true)
+		if err != nil {
+			return false, s.reconcileError("get records", zone, "", err)
+		}
+
+		for _, name := range z.recordNames() {
+			for _, desired := range s.desiredRecords(name, s.recordTTL(z, name)) {
+				if s.recordDrifted(zone, records, desired, multi) {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	logger.Debugf("DNS check found no drift")
+	return false, nil
+}
+
+// recordDrifted reports whether desired is missing from records. In multi mode
+// it only has to be part of the record set, otherwise the record must match.
+func (s *Sentinel) recordDrifted(zone string, records []libdns.Record, desired libdns.Record, multi bool) bool {
+	want := desired.RR()
+	for _, record := range records {
+		rr := record.RR()
+		if !sameName(rr.Name, want.Name, zone) || rr.Type != want.Type {
+			continue
+		}
+
+		if multi {
+			if sameContent(rr.Type, rr.Data, want.Data) {
+				return false
+			}
+			continue
+		}
+
+		if !sameContent(rr.Type, rr.Data, want.Data) || !s.ttlMatches(rr.TTL, want.TTL) {
+			logger.Infof("DNS %s record %s is %s, expected %s", want.Type, want.Name, rr.Data, want.Data)
+			return true
+		}
+		return false
+	}
+
+	logger.Infof("DNS %s record %s with %s is missing", want.Type, want.Name, want.Data)
+	return true
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// supportedDnsProviders lists all values accepted for SENTINEL_DNS_PROVIDER
+var supportedDnsProviders = []string{DnsProviderInwx, DnsProviderBunny, DnsProviderGcloud, DnsProviderPowerDNS, DnsProviderDesec, DnsProviderNamecheap, DnsProviderVultr, DnsProviderRoute53, DnsProviderNoop}
+
+// supportedOrchestrationTypes lists all values accepted for SENTINEL_ORCHESTRATION_TYPE
+var supportedOrchestrationTypes = []string{OrchestrationTypeDockerSwarm, OrchestrationTypeKubernetes, OrchestrationTypeNomad}
+
+// supportedIPSources lists all values accepted for SENTINEL_IP_SOURCE
+var supportedIPSources = []string{
+	IPSourceOrchestrator, IPSourceOrchestratorLabel, IPSourceNodeExternalIP, IPSourceExternalHTTP, IPSourceExternal, IPSourceStatic,
+}
+
+// supportedDockerIPSources lists all values accepted in SENTINEL_DOCKER_IP_SOURCES
+var supportedDockerIPSources = []string{DockerIPSourceLabel, DockerIPSourceAddr}
+
+// supportedIPFamilies lists all values accepted for SENTINEL_IP_FAMILY
+var supportedIPFamilies = []string{IPFamilyIPv4, IPFamilyIPv6, IPFamilyDual}
+
+// supportedModes lists all values accepted for SENTINEL_MODE
+var supportedModes = []string{ModeWatch, ModeOneshot, ModeMulti, ModeCoordinator}
+
+// supportedForceLeader lists all values accepted for SENTINEL_FORCE_LEADER
+var supportedForceLeader = []string{ForceLeaderAuto, ForceLeaderTrue, ForceLeaderFalse}
+
+// supportedK8sLeaderModes lists all values accepted for SENTINEL_K8S_LEADER_MODE
+var supportedK8sLeaderModes = []string{K8sLeaderModeControllerManager, K8sLeaderModeSelf}
+
+// Validate checks the configuration for obvious mistakes and returns all problems found
+func (c *Config) Validate() error {
+	var errs []error
+
+	if !isValidDomain(c.Domain) {
+		errs = append(errs, fmt.Errorf("domain %q is not a valid DNS name", c.Domain))
+	}
+
+	// Records are created below Domain, which therefore has to be part of the zone
+	zone := strings.ToLower(strings.TrimSuffix(c.Zone, "."))
+	domain := strings.ToLower(strings.TrimSuffix(c.Domain, "."))
+	if zone != "" && domain != zone && !strings.HasSuffix(domain, "."+zone) {
+		errs = append(errs, fmt.Errorf("domain %q is not within zone %q", c.Domain, c.Zone))
+	}
+
+	if len(c.Records) == 0 {
+		errs = append(errs, errors.New("no record configured"))
+	}
+
+	for _, z := range c.ExtraZones {
+		if !isValidDomain(z.Domain) {
+			errs = append(errs, fmt.Errorf("extra zone %q is not a valid DNS name", z.Domain))
+		}
+		if z.DnsProvider != "" && !slices.Contains(supportedDnsProviders, z.DnsProvider) {
+			errs = append(errs, fmt.Errorf("unsupported DNS provider %q for extra zone %q (supported: %s)",
+				z.DnsProvider, z.Domain, strings.Join(supportedDnsProviders, ", ")))
+		}
+		if c.RecordType == RecordTypeCNAME && slices.Contains(z.Records, "@") {
+			errs = append(errs, fmt.Errorf("record type CNAME is not allowed at the apex of extra zone %q", z.Domain))
+		}
+	}
+
+	if !slices.Contains(supportedDnsProviders, c.DnsProvider) {
+		errs = append(errs, fmt.Errorf("unsupported DNS provider %q (supported: %s)",
+			c.DnsProvider, strings.Join(supportedDnsProviders, ", ")))
+	}
+
+	if !slices.Contains(supportedOrchestrationTypes, c.OrchestrationType) {
+		errs = append(errs, fmt.Errorf("unsupported orchestration type %q (supported: %s)",
+			c.OrchestrationType, strings.Join(supportedOrchestrationTypes, ", ")))
+	}
+
+	switch c.RecordType {
+	case RecordTypeAddress:
+	case RecordTypeCNAME:
+		if c.CNAMETarget == "" && c.CNAMETargetLabel == "" {
+			errs = append(errs, errors.New("record type CNAME requires a CNAME target or CNAME target label"))
+		}
+		if c.CNAMETarget != "" && !isValidDomain(c.CNAMETarget) {
+			errs = append(errs, fmt.Errorf("CNAME target %q is not a valid DNS name", c.CNAMETarget))
+		}
+		// The apex carries the SOA and NS records, which a CNAME cannot coexist with
+		if domain == zone && (slices.Contains(c.Records, "@") || slices.Contains(c.Records, "")) {
+			errs = append(errs, errors.New("record type CNAME is not allowed at the zone apex"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported record type %q (supported: %s, %s)",
+			c.RecordType, RecordTypeAddress, RecordTypeCNAME))
+	}
+
+	if !slices.Contains(supportedModes, c.Mode) {
+		errs = append(errs, fmt.Errorf("unsupported mode %q (supported: %s)",
+			c.Mode, strings.Join(supportedModes, ", ")))
+	}
+
+	// A name can only hold a single CNAME, so round-robin needs address records
+	if c.Mode == ModeMulti && c.RecordType == RecordTypeCNAME {
+		errs = append(errs, fmt.Errorf("mode %s requires record type %s", ModeMulti, RecordTypeAddress))
+	}
+
+	if !slices.Contains(supportedForceLeader, c.ForceLeader) {
+		errs = append(errs, fmt.Errorf("unsupported force leader value %q (supported: %s)",
+			c.ForceLeader, strings.Join(supportedForceLeader, ", ")))
+	}
+
+	if c.OrchestrationType == OrchestrationTypeKubernetes && !slices.Contains(supportedK8sLeaderModes, c.K8sLeaderMode) {
+		errs = append(errs, fmt.Errorf("unsupported Kubernetes leader mode %q (supported: %s)",
+			c.K8sLeaderMode, strings.Join(supportedK8sLeaderModes, ", ")))
+	}
+
+	for _, source := range c.ipSources() {
+		if !slices.Contains(supportedIPSources, source) {
+			errs = append(errs, fmt.Errorf("unsupported IP source %q (supported: %s)",
+				source, strings.Join(supportedIPSources, ", ")))
+		}
+	}
+
+	if c.OrchestrationType == OrchestrationTypeDockerSwarm {
+		if len(c.DockerIPSources) == 0 {
+			errs = append(errs, fmt.Errorf("at least one Docker IP source is required (supported: %s)",
+				strings.Join(supportedDockerIPSources, ", ")))
+		}
+		for _, source := range c.DockerIPSources {
+			if !slices.Contains(supportedDockerIPSources, source) {
+				errs = append(errs, fmt.Errorf("unsupported Docker IP source %q (supported: %s)",
+					source, strings.Join(supportedDockerIPSources, ", ")))
+			}
+		}
+	}
+
+	if !slices.Contains(supportedIPFamilies, c.IPFamily) {
+		errs = append(errs, fmt.Errorf("unsupported IP family %q (supported: %s)",
+			c.IPFamily, strings.Join(supportedIPFamilies, ", ")))
+	}
+
+	if slices.Contains(c.ipSources(), IPSourceStatic) && c.ServerIP == "" && c.ServerIPv6 == "" {
+		errs = append(errs, errors.New("IP source static requires SENTINEL_SERVER_IP or SENTINEL_SERVER_IPV6"))
+	}
+
+	if slices.Contains(c.ipSources(), IPSourceExternalHTTP) || slices.Contains(c.ipSources(), IPSourceExternal) {
+		if u, err := url.Parse(c.IPEchoURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("IP echo URL %q is not a valid URL", c.IPEchoURL))
+		}
+	}
+
+	if _, err := parseIPTransform(c.IPTransform); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.HTTPSProxy != "" {
+		if err := validateProxyURL(c.HTTPSProxy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.ServerIP != "" {
+		if ip, err := netip.ParseAddr(c.ServerIP); err != nil {
+			errs = append(errs, fmt.Errorf("server IP %q is not a valid IP address", c.ServerIP))
+		} else if !c.AllowPrivateIP && c.IPTransform == "" && !isPublicIP(ip) {
+			errs = append(errs, fmt.Errorf("server IP %s is not a public address (set SENTINEL_ALLOW_PRIVATE_IP=true for internal DNS)", c.ServerIP))
+		}
+	}
+
+	if c.ServerIPv6 != "" {
+		if ip, err := netip.ParseAddr(c.ServerIPv6); err != nil || !ip.Is6() {
+			errs = append(errs, fmt.Errorf("server IPv6 %q is not a valid IPv6 address", c.ServerIPv6))
+		} else if !c.AllowPrivateIP && c.IPTransform == "" && !isPublicIP(ip) {
+			errs = append(errs, fmt.Errorf("server IPv6 %s is not a public address (set SENTINEL_ALLOW_PRIVATE_IP=true for internal DNS)", c.ServerIPv6))
+		}
+	}
+
+	if c.FallbackIP != "" {
+		if ip, err := netip.ParseAddr(c.FallbackIP); err != nil {
+			errs = append(errs, fmt.Errorf("fallback IP %q is not a valid IP address", c.FallbackIP))
+		} else if !c.AllowPrivateIP && !isPublicIP(ip) {
+			errs = append(errs, fmt.Errorf("fallback IP %s is not a public address (set SENTINEL_ALLOW_PRIVATE_IP=true for internal DNS)", c.FallbackIP))
+		}
+		if c.RecordType == RecordTypeCNAME {
+			errs = append(errs, fmt.Errorf("fallback IP requires record type %s", RecordTypeAddress))
+		}
+	}
+
+	if c.Mode == ModeCoordinator && (c.FallbackIP == "" || c.FallbackAfter <= 0) {
+		errs = append(errs, fmt.Errorf("mode %s requires SENTINEL_FALLBACK_IP and SENTINEL_FALLBACK_AFTER", ModeCoordinator))
+	}
+
+	// The leader only writes its heartbeat when it reconciles, a heartbeat
+	// delay shorter than that would make the coordinator fall back needlessly
+	if c.FallbackAfter > 0 && c.Mode != ModeCoordinator && c.Mode != ModeOneshot &&
+		(c.ReconcileInterval <= 0 || c.FallbackAfter < 2*c.ReconcileInterval) {
+		errs = append(errs, fmt.Errorf("fallback after %s requires a reconcile interval of at most half of it, got %s", c.FallbackAfter, c.ReconcileInterval))
+	}
+
+	if f := c.RecordOptions.Failover; f != "" && f != "PRIMARY" && f != "SECONDARY" {
+		errs = append(errs, fmt.Errorf("unsupported record failover %q (supported: PRIMARY, SECONDARY)", f))
+	}
+	if o := c.RecordOptions; !o.IsZero() && o.SetIdentifier == "" {
+		errs = append(errs, fmt.Errorf("record weight, failover and health check require SENTINEL_RECORD_SET_ID"))
+	}
+	if o := c.RecordOptions; o.Failover != "" && o.Weight != 0 {
+		errs = append(errs, fmt.Errorf("record weight and failover are mutually exclusive"))
+	}
+	if w := c.RecordOptions.Weight; w < 0 || w > 255 {
+		errs = append(errs, fmt.Errorf("record weight must be between 0 and 255, got %d", w))
+	}
+
+	if c.LagThreshold < 0 {
+		errs = append(errs, fmt.Errorf("reconcile lag threshold must not be negative, got %s", c.LagThreshold))
+	}
+
+	if c.TimerJitter >= 100 {
+		errs = append(errs, fmt.Errorf("timer jitter must be below 100%%, got %d%%", c.TimerJitter))
+	}
+
+	if c.RecordTTL < 0 {
+		errs = append(errs, fmt.Errorf("record TTL must not be negative, got %d", c.RecordTTL))
+	}
+
+	return errors.Join(errs...)
+}
+
+// isPublicIP checks whether ip is a global unicast address outside of the
+// private, loopback and link-local ranges
+func isPublicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
+}
+
+// isValidDomain checks whether name is a plausible fully qualified DNS name
+func isValidDomain(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 || !strings.Contains(name, ".") {
+		return false
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			isAlnum := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+			if !isAlnum && r != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig holds the settings read from SENTINEL_CONFIG_FILE, keyed like the
+// environment variables without their SENTINEL_ prefix. getEnv consults it for
+// variables that are not set, so the environment always takes precedence.
+var fileConfig map[string]string
+
+// loadConfigFile reads the YAML config file at path into fileConfig. Keys are
+// the names of the environment variables in lower case without prefix, e.g.
+// "record_ttl". Lists are joined with commas, nested maps name their keys
+// after the parent, e.g. inwx: {user: ...} sets INWX_USER. Two keys are
+// structured for readability:
+//
+//	record_ttls:      # SENTINEL_RECORD_TTL_<record>
+//	  www: 3600
+//	extra_zones:      # SENTINEL_EXTRA_ZONES
+//	  - domain: example.org
+//	    provider: bunny
+//	    records: [www, api]
+func loadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %v", err)
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("could not parse config file %s: %v", path, err)
+	}
+
+	values := make(map[string]string)
+	for key, value := range raw {
+		key = strings.ToUpper(key)
+		switch key {
+		case "RECORD_TTLS":
+			ttls, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("config file %s: record_ttls must map records to TTLs", path)
+			}
+			for record, ttl := range ttls {
+				values["RECORD_TTL_"+envName(record)] = fmt.Sprint(ttl)
+			}
+		case "EXTRA_ZONES":
+			zones, err := extraZonesValue(value)
+			if err != nil {
+				return fmt.Errorf("config file %s: %v", path, err)
+			}
+			values[key] = zones
+		default:
+			if err := flattenConfigValue(values, key, value); err != nil {
+				return fmt.Errorf("config file %s: %v", path, err)
+			}
+		}
+	}
+
+	fileConfig = values
+	return nil
+}
+
+// flattenConfigValue stores value under key, descending into nested maps
+func flattenConfigValue(values map[string]string, key string, value any) error {
+	switch v := value.(type) {
+	case nil:
+		values[key] = ""
+	case map[string]any:
+		for subKey, subValue := range v {
+			if err := flattenConfigValue(values, key+"_"+strings.ToUpper(subKey), subValue); err != nil {
+				return err
+			}
+		}
+	case []any:
+		var items []string
+		for _, item := range v {
+			if _, ok := item.(map[string]any); ok {
+				return fmt.Errorf("%s must be a list of values", strings.ToLower(key))
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		values[key] = strings.Join(items, ",")
+	default:
+		values[key] = fmt.Sprint(v)
+	}
+	return nil
+}
+
+// extraZonesValue turns the extra_zones list into the SENTINEL_EXTRA_ZONES
+// notation, a plain string is taken as is
+func extraZonesValue(value any) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	list, ok := value.([]any)
+	if !ok {
+		return "", fmt.Errorf("extra_zones must be a list of zones")
+	}
+
+	var entries []string
+	for _, item := range list {
+		zone, ok := item.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("extra_zones entries need a domain")
+		}
+		domain, _ := zone["domain"].(string)
+		if domain == "" {
+			return "", fmt.Errorf("extra_zones entries need a domain")
+		}
+
+		entry := domain
+		if provider, _ := zone["provider"].(string); provider != "" {
+			entry += ":" + provider
+		}
+		if records, ok := zone["records"]; ok {
+			var names []string
+			if list, ok := records.([]any); ok {
+				for _, name := range list {
+					names = append(names, fmt.Sprint(name))
+				}
+			} else if records != nil {
+				names = append(names, fmt.Sprint(records))
+			}
+			entry += "=" + strings.Join(names, ",")
+		}
+		entries = append(entries, entry)
+	}
+	return strings.Join(entries, ";"), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/desec"
+	"github.com/libdns/libdns"
+)
+
+// desecMinTTL is the lowest TTL deSEC accepts for domains of regular accounts
+const desecMinTTL = 3600
+
+// DesecDnsClient wraps the deSEC provider to report domains missing from the
+// account as ErrZoneNotFound
+type DesecDnsClient struct {
+	*desec.Provider
+}
+
+// GetRecords lists all records in zone
+func (c *DesecDnsClient) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	records, err := c.Provider.GetRecords(ctx, zone)
+	return records, c.explain(zone, err)
+}
+
+// AppendRecords adds records to zone
+func (c *DesecDnsClient) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.AppendRecords(ctx, zone, records)
+	return records, c.explain(zone, err)
+}
+
+// SetRecords replaces the records of zone sharing name and type with records
+func (c *DesecDnsClient) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.SetRecords(ctx, zone, records)
+	return records, c.explain(zone, err)
+}
+
+// DeleteRecords removes records from zone
+func (c *DesecDnsClient) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.DeleteRecords(ctx, zone, records)
+	return records, c.explain(zone, err)
+}
+
+// explain wraps the error the provider returns for domains missing from the account
+func (c *DesecDnsClient) explain(zone string, err error) error {
+	if err != nil && strings.Contains(err.Error(), "unexpected status code 404") {
+		return fmt.Errorf("domain %s not found in the deSEC account: %w", strings.TrimSuffix(zone, "."), ErrZoneNotFound)
+	}
+	return err
+}
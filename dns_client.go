@@ -1,8 +1,58 @@
 package main
 
-import "github.com/libdns/libdns"
+import (
+	"strings"
+
+	"github.com/libdns/libdns"
+)
 
 type DnsClient interface {
 	libdns.RecordGetter
+	libdns.RecordAppender
 	libdns.RecordSetter
+	libdns.RecordDeleter
+}
+
+// RecordOptions are provider specific routing options of the managed records,
+// e.g. Route53 weighted or failover routing
+type RecordOptions struct {
+	Weight        int64
+	Failover      string // "PRIMARY" or "SECONDARY"
+	SetIdentifier string
+	HealthCheckID string
+}
+
+// IsZero reports whether no option is set
+func (o RecordOptions) IsZero() bool {
+	return o == RecordOptions{}
+}
+
+// RecordDecorator is implemented by DNS clients supporting RecordOptions. It
+// returns the record to write, carrying the options in whatever form the
+// provider expects. Providers without it get the plain record.
+type RecordDecorator interface {
+	DecorateRecord(record libdns.Record, options RecordOptions) libdns.Record
+}
+
+// normalizeName converts a record name into its lower-case form relative to zone.
+// Providers return names relative ("lb"), fully qualified ("lb.example.com") or
+// fully qualified with a trailing dot ("lb.example.com."), so both sides of a
+// comparison are normalized first. The apex is returned as "@", whether the
+// provider calls it "@", "" or by the zone name.
+func normalizeName(name, zone string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+
+	if name == "" || name == "@" || name == zone {
+		return "@"
+	}
+	if zone == "" {
+		return name
+	}
+	return strings.TrimSuffix(name, "."+zone)
+}
+
+// sameName reports whether two record names refer to the same name within zone
+func sameName(a, b, zone string) bool {
+	return normalizeName(a, zone) == normalizeName(b, zone)
 }
@@ -2,18 +2,61 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
+// DefaultDockerHost is the Docker daemon address used when SENTINEL_DOCKER_HOST is unset
+const DefaultDockerHost = "unix:///var/run/docker.sock"
+
+// Bounds of the backoff between reconnects to the Docker event stream
+const dockerReconnectMinDelay = time.Second
+const dockerReconnectMaxDelay = time.Minute
+
+// Sources of a swarm node's IP, tried in the order of SENTINEL_DOCKER_IP_SOURCES
+const (
+	DockerIPSourceLabel = "label" // the public IP node label
+	DockerIPSourceAddr  = "addr"  // the address the node advertises to the swarm
+)
+
+// dockerRequestTimeout bounds regular Docker API requests, the event stream is
+// only limited by its context
+const dockerRequestTimeout = 30 * time.Second
+
 // DockerClient handles communication with the Docker API
 type DockerClient struct {
-	client *http.Client
+	client       *http.Client
+	streamClient *http.Client
+	baseURL      string
+
+	// publicIPLabel is the node label holding the public IP, publicIPv6Label
+	// an optional label holding its IPv6 address
+	publicIPLabel   string
+	publicIPv6Label string
+
+	// ipSources are the places the node IP is read from, in order
+	ipSources []string
+
+	// requirePublicIP is set if the public IP is read from the node, labelOnly
+	// if it is read from the public IP labels only
+	requirePublicIP bool
+	labelOnly       bool
+
+	// watchAllNodes subscribes to updates of every node instead of only the
+	// current one, so the leader notices other nodes leaving in multi mode
+	watchAllNodes bool
 }
 
 // DockerEvent represents a Docker event from the API
@@ -36,34 +79,156 @@ type NodeInfo struct {
 		Hostname string `json:"Hostname"`
 	} `json:"Description"`
 	Spec struct {
-		Labels map[string]string `json:"Labels"`
+		Labels       map[string]string `json:"Labels"`
+		Availability string            `json:"Availability"`
 	} `json:"Spec"`
+	Status struct {
+		State string `json:"State"`
+		Addr  string `json:"Addr"`
+	} `json:"Status"`
 }
 
-// NewDockerClient creates a new Docker API client
-func NewDockerClient() *DockerClient {
-	return &DockerClient{
-		client: &http.Client{
-			Transport: &http.Transport{
-				DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-					return net.Dial("unix", "/var/run/docker.sock")
-				},
+// NewDockerClient creates a new Docker API client for the daemon at config.DockerHost,
+// which is either a unix:// socket path or a tcp:// address
+func NewDockerClient(config *Config) (*DockerClient, error) {
+	host, err := url.Parse(config.DockerHost)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Docker host %q: %v", config.DockerHost, err)
+	}
+
+	var transport *http.Transport
+	var baseURL string
+
+	switch host.Scheme {
+	case "unix":
+		socketPath := host.Path
+		transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
 			},
-		},
+		}
+		baseURL = "http://localhost"
+	case "tcp":
+		transport = &http.Transport{}
+		scheme := "http"
+
+		certFile, keyFile, caFile, err := dockerTLSFiles(config)
+		if err != nil {
+			return nil, err
+		}
+
+		if certFile != "" || keyFile != "" || caFile != "" {
+			tlsConfig, err := dockerTLSConfig(certFile, keyFile, caFile)
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = tlsConfig
+			scheme = "https"
+		}
+
+		baseURL = scheme + "://" + host.Host
+	default:
+		return nil, fmt.Errorf("unsupported Docker host scheme %q (supported: unix, tcp)", host.Scheme)
+	}
+
+	return NewDockerClientWithTransport(config, transport, baseURL), nil
+}
+
+// NewDockerClientWithTransport creates a Docker API client sending its requests
+// for baseURL through transport, e.g. to reach the daemon through a proxy or to
+// test against a mock server
+func NewDockerClientWithTransport(config *Config, transport http.RoundTripper, baseURL string) *DockerClient {
+	// Without a fallback source a node lacking the label has no IP to publish
+	onlyOrchestrator, onlyLabel := true, true
+	for _, source := range config.ipSources() {
+		onlyOrchestrator = onlyOrchestrator && (source == IPSourceOrchestrator || source == IPSourceOrchestratorLabel)
+		onlyLabel = onlyLabel && source == IPSourceOrchestratorLabel
+	}
+
+	return &DockerClient{
+		client:          &http.Client{Transport: transport, Timeout: dockerRequestTimeout},
+		streamClient:    &http.Client{Transport: transport},
+		baseURL:         strings.TrimSuffix(baseURL, "/"),
+		publicIPLabel:   config.PublicIPLabel,
+		publicIPv6Label: config.PublicIPv6Label,
+		ipSources:       config.DockerIPSources,
+		requirePublicIP: config.RecordType != RecordTypeCNAME && config.ServerIP == "" && onlyOrchestrator,
+		labelOnly:       onlyLabel,
+		watchAllNodes:   config.Mode == ModeMulti,
+	}
+}
+
+// dockerTLSFiles returns the TLS files for a Docker daemon over TCP. Files not set
+// through SENTINEL_DOCKER_TLS_* are taken from DOCKER_CERT_PATH like the docker CLI
+// does, which defaults to ~/.docker if DOCKER_TLS_VERIFY is set.
+func dockerTLSFiles(config *Config) (certFile, keyFile, caFile string, err error) {
+	certFile, keyFile, caFile = config.DockerTLSCert, config.DockerTLSKey, config.DockerTLSCA
+	tlsVerify := os.Getenv("DOCKER_TLS_VERIFY") != ""
+
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	if certPath == "" && tlsVerify {
+		if home, err := os.UserHomeDir(); err == nil {
+			certPath = filepath.Join(home, ".docker")
+		}
+	}
+
+	if certPath != "" {
+		certFile = cmp.Or(certFile, filepath.Join(certPath, "cert.pem"))
+		keyFile = cmp.Or(keyFile, filepath.Join(certPath, "key.pem"))
+		caFile = cmp.Or(caFile, filepath.Join(certPath, "ca.pem"))
+	}
+
+	if tlsVerify {
+		for _, file := range []string{certFile, keyFile, caFile} {
+			if _, err := os.Stat(file); err != nil {
+				return "", "", "", fmt.Errorf("DOCKER_TLS_VERIFY is set, but the TLS file %q is missing (set DOCKER_CERT_PATH or SENTINEL_DOCKER_TLS_*): %v", file, err)
+			}
+		}
 	}
+
+	return certFile, keyFile, caFile, nil
+}
+
+// dockerTLSConfig builds the TLS configuration for a Docker daemon reached over TCP
+func dockerTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading Docker TLS client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Docker TLS CA: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in Docker TLS CA %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
 }
 
 // IsSwarmActive checks if Docker is running in swarm mode
 func (d *DockerClient) IsSwarmActive() bool {
-	req, err := http.NewRequest("GET", "http://localhost/swarm", nil)
+	req, err := http.NewRequest("GET", d.baseURL+"/swarm", nil)
 	if err != nil {
-		log.Printf("Error creating swarm request: %v", err)
+		logger.Errorf("Error creating swarm request: %v", err)
 		return false
 	}
 
 	resp, err := d.client.Do(req)
 	if err != nil {
-		log.Printf("Error connecting to Docker API: %v", err)
+		logger.Errorf("Error connecting to Docker API: %v", err)
 		return false
 	}
 	defer resp.Body.Close()
@@ -73,7 +238,7 @@ func (d *DockerClient) IsSwarmActive() bool {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&swarmInfo); err != nil {
-		log.Printf("Error parsing swarm response: %v", err)
+		logger.Errorf("Error parsing swarm response: %v", err)
 		return false
 	}
 
@@ -81,77 +246,238 @@ func (d *DockerClient) IsSwarmActive() bool {
 	return swarmInfo.ID != ""
 }
 
+// GetConfigurationErrors checks the prerequisites for running on Docker Swarm, so
+// misconfigured deployments fail at startup with an actionable message
 func (d *DockerClient) GetConfigurationErrors() []string {
 	var errs []string
+
+	if err := d.ping(); err != nil {
+		return append(errs, fmt.Sprintf("Docker API not reachable at %s, is the Docker socket mounted? (%v)", d.baseURL, err))
+	}
+
 	if !d.IsSwarmActive() {
-		errs = append(errs, "Docker is not running in swarm mode")
+		return append(errs, "Docker is not running in swarm mode, run 'docker swarm init' or join a swarm")
+	}
+
+	if d.requirePublicIP {
+		nodeID, err := d.GetCurrentNodeID()
+		if err != nil {
+			return append(errs, fmt.Sprintf("Could not determine the swarm node ID: %v", err))
+		}
+		node, err := d.getNode(nodeID)
+		if err != nil {
+			return append(errs, fmt.Sprintf("Could not read swarm node %s: %v", nodeID, err))
+		}
+		sources := strings.Join(d.ipSources, ",")
+		_, ok := d.nodePublicIP(node)
+		if d.labelOnly {
+			sources = DockerIPSourceLabel
+			ok = node.Spec.Labels[d.publicIPLabel] != "" || (d.publicIPv6Label != "" && node.Spec.Labels[d.publicIPv6Label] != "")
+		}
+		if !ok {
+			message := fmt.Sprintf("Node %s has no IP from sources %s, set it with 'docker node update --label-add %s=<ip> %s' or set SENTINEL_SERVER_IP",
+				nodeID, sources, d.publicIPLabel, nodeID)
+			// Only the leader publishes its IP, other nodes can be labelled before they take over
+			if node.ManagerStatus != nil && node.ManagerStatus.Leader {
+				errs = append(errs, message)
+			} else {
+				logger.Warnf("%s, DNS cannot fail over to it", message)
+			}
+		}
 	}
 
 	return errs
 }
 
-// IsLeader checks if this node is the swarm leader
-func (d *DockerClient) IsLeader() bool {
+// ping checks that the Docker API answers
+func (d *DockerClient) ping() error {
+	req, err := http.NewRequest("GET", d.baseURL+"/_ping", nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// IsLeader checks if this node is the swarm leader. An error means leadership
+// could not be determined, e.g. because the Docker API is unreachable.
+func (d *DockerClient) IsLeader(ctx context.Context) (bool, error) {
 	currentNodeID, err := d.GetCurrentNodeID()
 	if err != nil {
-		log.Printf("Error getting current node ID: %v", err)
-		return false
+		return false, fmt.Errorf("error getting current node ID: %v", err)
 	}
 
-	req, err := http.NewRequest("GET", "http://localhost/nodes", nil)
+	nodes, err := d.listNodes(ctx)
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		return false
+		return false, err
+	}
+
+	for _, node := range nodes {
+		if node.ID == currentNodeID && node.ManagerStatus != nil && node.ManagerStatus.Leader {
+			// A leading manager that is down or being drained is about to go
+			// away, so DNS must not point to it
+			if !nodeAvailable(&node) {
+				logger.Warnf("Node %s leads the swarm but is %s/%s, not publishing its IP",
+					node.Description.Hostname, node.Status.State, node.Spec.Availability)
+				return false, nil
+			}
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// nodeAvailable reports whether node is ready and accepts tasks
+func nodeAvailable(node *NodeInfo) bool {
+	return node.Status.State == "ready" && node.Spec.Availability == "active"
+}
+
+// listNodes retrieves all nodes of the swarm
+func (d *DockerClient) listNodes(ctx context.Context) ([]NodeInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.baseURL+"/nodes", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
 	resp, err := d.client.Do(req)
 	if err != nil {
-		log.Printf("Error connecting to Docker API: %v", err)
-		return false
+		return nil, fmt.Errorf("error connecting to Docker API: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if err := dockerResponseError(resp); err != nil {
+		return nil, fmt.Errorf("error listing nodes: %v", err)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading response body: %v", err)
-		return false
+		return nil, fmt.Errorf("error reading response body: %v", err)
 	}
 
-	// Only log the raw response if log level is DEBUG
-	if getEnv("LOG_LEVEL", "INFO") == "DEBUG" {
-		log.Printf("Raw nodes response: %s", string(body))
-	}
+	logger.Debugf("Raw nodes response: %s", string(body))
 
 	var nodes []NodeInfo
 	if err := json.Unmarshal(body, &nodes); err != nil {
-		log.Printf("Error parsing nodes response: %v", err)
-		return false
+		return nil, fmt.Errorf("error parsing nodes response: %v", err)
 	}
 
+	return nodes, nil
+}
+
+// ListReadyNodeIPs returns the public addresses of all ready and active nodes
+// per IP family, nodes without an IP from any source are skipped
+func (d *DockerClient) ListReadyNodeIPs(ctx context.Context) ([]map[string]string, error) {
+	nodes, err := d.listNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeIPs []map[string]string
 	for _, node := range nodes {
-		if node.ID == currentNodeID && node.ManagerStatus != nil && node.ManagerStatus.Leader {
-			return true
+		if !nodeAvailable(&node) {
+			continue
+		}
+
+		ips := d.nodePublicIPs(&node)
+		if len(ips) == 0 {
+			logger.Warnf("Node %s has no IP from sources %s, skipping it", node.Description.Hostname, strings.Join(d.ipSources, ","))
+			continue
 		}
+		nodeIPs = append(nodeIPs, ips)
 	}
 
-	return false
+	return nodeIPs, nil
 }
 
-// WatchEvents watches Docker events for node updates
-func (d *DockerClient) WatchEvents(callback func()) {
-	req, err := http.NewRequest("GET", "http://localhost/events?filters={\"scope\":[\"swarm\"]}", nil)
+// WatchEvents watches Docker events for node updates until ctx is cancelled,
+// reconnecting with backoff whenever the event stream ends
+func (d *DockerClient) WatchEvents(ctx context.Context, callback func()) {
+	delay := dockerReconnectMinDelay
+	reconnect := false
+
+	for {
+		connected := d.streamEvents(ctx, callback, reconnect)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Reset the backoff once a stream was established successfully
+		if connected {
+			delay = dockerReconnectMinDelay
+		}
+
+		logger.Warnf("Docker event stream ended, reconnecting in %s", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, dockerReconnectMaxDelay)
+		reconnect = true
+	}
+}
+
+// eventsURL builds the events endpoint with server-side filters, so the daemon only
+// sends update events of the current node (of all nodes in multi mode) instead
+// of every swarm event
+func (d *DockerClient) eventsURL() string {
+	filters := map[string][]string{
+		"scope": {"swarm"},
+		"type":  {"node"},
+		"event": {"update"},
+	}
+
+	if d.watchAllNodes {
+		filters["event"] = append(filters["event"], "remove")
+	} else if nodeID, err := d.GetCurrentNodeID(); err != nil {
+		logger.Warnf("Could not get current node ID, watching all nodes: %v", err)
+	} else {
+		filters["node"] = []string{nodeID}
+	}
+
+	// A map of string slices always marshals successfully
+	encoded, _ := json.Marshal(filters)
+
+	query := url.Values{}
+	query.Set("filters", string(encoded))
+	return d.baseURL + "/events?" + query.Encode()
+}
+
+// streamEvents reads the Docker event stream until it ends and reports whether
+// the stream could be established. After a reconnect callback is called once
+// to catch up on changes missed while disconnected.
+func (d *DockerClient) streamEvents(ctx context.Context, callback func(), reconnect bool) bool {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.eventsURL(), nil)
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		return
+		logger.Errorf("Error creating request: %v", err)
+		return false
 	}
 
-	resp, err := d.client.Do(req)
+	resp, err := d.streamClient.Do(req)
 	if err != nil {
-		log.Printf("Error connecting to Docker API: %v", err)
-		return
+		if ctx.Err() == nil {
+			logger.Errorf("Error connecting to Docker API: %v", err)
+		}
+		return false
 	}
 	defer resp.Body.Close()
 
+	if reconnect {
+		logger.Infof("Reconnected to Docker event stream, checking leader status...")
+		callback()
+	}
+
 	scanner := bufio.NewScanner(resp.Body)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -161,25 +487,29 @@ func (d *DockerClient) WatchEvents(callback func()) {
 
 		var event DockerEvent
 		if err := json.Unmarshal([]byte(line), &event); err != nil {
-			log.Printf("Error parsing event: %v", err)
+			logger.Errorf("Error parsing event: %v", err)
 			continue
 		}
 
-		if event.Type == "node" && event.Action == "update" {
-			log.Println("Node update detected, checking leader status...")
+		// Node updates cover leadership changes as well as label changes,
+		// so a changed public IP label is picked up on the next check
+		if event.Type == "node" && (event.Action == "update" || event.Action == "remove") {
+			logger.Infof("Node %s detected, checking leader status...", event.Action)
 			callback()
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Printf("Error reading events: %v", err)
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		logger.Errorf("Error reading events: %v", err)
 	}
+
+	return true
 }
 
 // GetCurrentNodeID retrieves the ID of the current node from Docker API
 func (d *DockerClient) GetCurrentNodeID() (string, error) {
 	// Docker API endpoint for information about the current node
-	req, err := http.NewRequest("GET", "http://localhost/info", nil)
+	req, err := http.NewRequest("GET", d.baseURL+"/info", nil)
 	if err != nil {
 		return "", fmt.Errorf("error creating request: %v", err)
 	}
@@ -209,7 +539,7 @@ func (d *DockerClient) GetCurrentNodeID() (string, error) {
 
 // getNode retrieves detailed node information from Docker API
 func (d *DockerClient) getNode(nodeID string) (*NodeInfo, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost/nodes/%s", nodeID), nil)
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/nodes/%s", d.baseURL, nodeID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
@@ -220,6 +550,10 @@ func (d *DockerClient) getNode(nodeID string) (*NodeInfo, error) {
 	}
 	defer resp.Body.Close()
 
+	if err := dockerResponseError(resp); err != nil {
+		return nil, fmt.Errorf("error getting node %s: %v", nodeID, err)
+	}
+
 	var node NodeInfo
 	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
 		return nil, fmt.Errorf("error parsing node response: %v", err)
@@ -228,6 +562,22 @@ func (d *DockerClient) getNode(nodeID string) (*NodeInfo, error) {
 	return &node, nil
 }
 
+// dockerResponseError returns the error message of a failed Docker API
+// response, which would otherwise be decoded as an empty result
+func dockerResponseError(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body); err != nil || body.Message == "" {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return fmt.Errorf("%s (%s)", body.Message, resp.Status)
+}
+
 // GetNodeName retrieves the current node name from Docker Swarm
 func (d *DockerClient) GetNodeName() (string, error) {
 	nodeID, err := d.GetCurrentNodeID()
@@ -262,7 +612,33 @@ func (d *DockerClient) GetNodeLabel(nodeID, labelName string) (string, error) {
 	return value, nil
 }
 
-// GetNodePublicIP retrieves the public IP address from the node's label
+// GetCurrentNodeLabel retrieves a specific label from the current node
+func (d *DockerClient) GetCurrentNodeLabel(labelName string) (string, error) {
+	nodeID, err := d.GetCurrentNodeID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get node ID: %v", err)
+	}
+
+	return d.GetNodeLabel(nodeID, labelName)
+}
+
+// IsNodeReady checks whether the current node is ready and not paused or drained
+func (d *DockerClient) IsNodeReady() (bool, error) {
+	nodeID, err := d.GetCurrentNodeID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get node ID: %v", err)
+	}
+
+	node, err := d.getNode(nodeID)
+	if err != nil {
+		return false, err
+	}
+
+	return nodeAvailable(node), nil
+}
+
+// GetNodePublicIP retrieves the public IP address of the current node from the
+// configured sources
 func (d *DockerClient) GetNodePublicIP() (string, error) {
 	// First get the node ID
 	nodeID, err := d.GetCurrentNodeID()
@@ -270,11 +646,97 @@ func (d *DockerClient) GetNodePublicIP() (string, error) {
 		return "", fmt.Errorf("failed to get node ID: %v", err)
 	}
 
-	// Then retrieve the public IP label
-	publicIP, err := d.GetNodeLabel(nodeID, "public_ip")
+	node, err := d.getNode(nodeID)
 	if err != nil {
-		return "", fmt.Errorf("failed to get public_ip label: %v", err)
+		return "", err
+	}
+
+	publicIP, exists := d.nodePublicIP(node)
+	if !exists {
+		return "", fmt.Errorf("no IP found on node %s (sources: %s)", nodeID, strings.Join(d.ipSources, ","))
 	}
 
 	return publicIP, nil
 }
+
+// GetNodePublicIPs returns the public addresses of the current node per IP
+// family, read from the configured sources in order
+func (d *DockerClient) GetNodePublicIPs() (map[string]string, error) {
+	nodeID, err := d.GetCurrentNodeID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node ID: %v", err)
+	}
+
+	node, err := d.getNode(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := d.nodePublicIPs(node)
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no IP found on node %s (sources: %s)", nodeID, strings.Join(d.ipSources, ","))
+	}
+	return ips, nil
+}
+
+// nodePublicIPs returns the public addresses of node per IP family, read from
+// the configured sources in order
+func (d *DockerClient) nodePublicIPs(node *NodeInfo) map[string]string {
+	ips := make(map[string]string)
+	for _, source := range d.ipSources {
+		switch source {
+		case DockerIPSourceLabel:
+			addIPByFamily(ips, node.Spec.Labels[d.publicIPLabel])
+			if d.publicIPv6Label != "" {
+				addIPByFamily(ips, node.Spec.Labels[d.publicIPv6Label])
+			}
+		case DockerIPSourceAddr:
+			if node.Status.Addr != "0.0.0.0" {
+				addIPByFamily(ips, node.Status.Addr)
+			}
+		}
+	}
+	return ips
+}
+
+// GetNodeExternalIPs returns the address the current node advertises to the swarm
+func (d *DockerClient) GetNodeExternalIPs() (map[string]string, error) {
+	nodeID, err := d.GetCurrentNodeID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node ID: %v", err)
+	}
+
+	node, err := d.getNode(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make(map[string]string)
+	if node.Status.Addr != "0.0.0.0" {
+		addIPByFamily(ips, node.Status.Addr)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("node %s advertises no address", nodeID)
+	}
+	return ips, nil
+}
+
+// nodePublicIP returns the IP of node from the first source that has one: the
+// public IP label or the address the node advertises to the swarm
+func (d *DockerClient) nodePublicIP(node *NodeInfo) (string, bool) {
+	for _, source := range d.ipSources {
+		switch source {
+		case DockerIPSourceLabel:
+			if publicIP, exists := node.Spec.Labels[d.publicIPLabel]; exists {
+				return publicIP, true
+			}
+		case DockerIPSourceAddr:
+			// Managers may report an unspecified address before the swarm settled
+			if addr := node.Status.Addr; addr != "" && addr != "0.0.0.0" {
+				return addr, true
+			}
+		}
+	}
+
+	return "", false
+}
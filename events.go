@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const EventLeaderAcquired = "leader_acquired"
+const EventLeaderLost = "leader_lost"
+const EventDNSUpdated = "dns_updated"
+const EventDNSRemoved = "dns_removed"
+const EventReconciled = "reconciled"
+
+const EventResultSucceeded = "succeeded"
+const EventResultFailed = "failed"
+const EventResultDryRun = "dry_run"
+const EventResultSkipped = "skipped"
+
+// Event is a line of the NDJSON event log written to SENTINEL_EVENT_LOG
+type Event struct {
+	Timestamp time.Time `json:"ts"`
+	Event     string    `json:"event"`
+	Node      string    `json:"node"`
+	Record    string    `json:"record,omitempty"`
+	Old       string    `json:"old,omitempty"`
+	New       string    `json:"new,omitempty"`
+	Result    string    `json:"result,omitempty"`
+}
+
+// eventLog writes events as one JSON object per line, separate from the human
+// readable log
+type eventLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// openEventLog opens the event log target: "-" for stdout, "fd:<n>" for a file
+// descriptor inherited from the parent process, otherwise a file appended to
+func openEventLog(target string) (*eventLog, error) {
+	if target == "-" {
+		return &eventLog{w: os.Stdout}, nil
+	}
+
+	if raw, ok := strings.CutPrefix(target, "fd:"); ok {
+		fd, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event log file descriptor %q", raw)
+		}
+		return &eventLog{w: os.NewFile(uintptr(fd), target)}, nil
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening event log: %v", err)
+	}
+	return &eventLog{w: file}, nil
+}
+
+// write appends event as a single line, so concurrent events never interleave
+func (l *eventLog) write(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(line, '\n'))
+	return err
+}
+
+// emitEvent writes event to the event log, if one is configured. Write errors
+// are only logged and never affect the DNS reconcile.
+func (s *Sentinel) emitEvent(event Event) {
+	if s.events == nil {
+		return
+	}
+
+	event.Node = s.nodeName
+	event.Timestamp = time.Now().UTC()
+	if err := s.events.write(event); err != nil {
+		logger.Errorf("Error writing %s event: %v", event.Event, err)
+	}
+}
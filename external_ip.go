@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// DefaultIPEchoURL is the service queried for the egress IP when SENTINEL_IP_SOURCE=external-http
+const DefaultIPEchoURL = "https://api.ipify.org"
+
+// discoverExternalIP asks an HTTP echo service for the public egress IP of this host
+func discoverExternalIP(echoURL string) (string, error) {
+	client := &http.Client{Transport: httpTransport, Timeout: 10 * time.Second}
+
+	resp, err := client.Get(echoURL)
+	if err != nil {
+		return "", fmt.Errorf("error querying %s: %v", echoURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status from %s: %s", echoURL, resp.Status)
+	}
+
+	// An address never exceeds a few dozen bytes, anything longer is not an echo response
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", fmt.Errorf("error reading response from %s: %v", echoURL, err)
+	}
+
+	ip, err := netip.ParseAddr(strings.TrimSpace(string(body)))
+	if err != nil {
+		return "", fmt.Errorf("response from %s is not an IP address: %v", echoURL, err)
+	}
+
+	return ip.Unmap().String(), nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// heartbeatRecordPrefix is prepended to the first managed record name to form
+// the name of the TXT record the leader proves it is alive with
+const heartbeatRecordPrefix = "_sentinel-heartbeat"
+
+// heartbeatRecordName returns the name of the heartbeat TXT record next to name
+func heartbeatRecordName(name string) string {
+	if name == "" || name == "@" {
+		return heartbeatRecordPrefix
+	}
+	return heartbeatRecordPrefix + "." + name
+}
+
+// heartbeatRecord returns the zone and name of the heartbeat record, which
+// lives next to the first record of the primary zone
+func (s *Sentinel) heartbeatRecord() (zone, name string) {
+	z := s.zones()[0]
+	return z.zone(), heartbeatRecordName(z.recordNames()[0])
+}
+
+// stampHeartbeat writes the heartbeat record if the last one is older than a
+// quarter of the fallback delay, so the coordinator sees a healthy leader
+func (s *Sentinel) stampHeartbeat(ctx context.Context) {
+	if s.Config.FallbackAfter <= 0 || time.Since(s.lastHeartbeat) < s.Config.FallbackAfter/4 {
+		return
+	}
+
+	zone, name := s.heartbeatRecord()
+	text := fmt.Sprintf("heritage=sentinel,sentinel/leader=%s,sentinel/heartbeat=%d", s.nodeName, time.Now().Unix())
+	if s.Config.DryRun {
+		logger.Infof("Dry run: would write leader heartbeat to TXT record %s", name)
+		return
+	}
+
+	err := s.withRetry(ctx, "set TXT record "+name, func(ctx context.Context) error {
+		defer observeDnsCall("set_records", time.Now())
+		defer s.invalidateRecords(zone)
+
+		_, err := s.dnsClient(zone).SetRecords(ctx, zone, []libdns.Record{libdns.TXT{
+			Name: name,
+			TTL:  time.Duration(s.Config.RecordTTL) * time.Second,
+			Text: text,
+		}})
+		return err
+	})
+	if err != nil {
+		logger.Warnf("Could not write leader heartbeat: %v", s.reconcileError("set", zone, name, err))
+		return
+	}
+	logger.Debugf("Wrote leader heartbeat to TXT record %s", name)
+	s.lastHeartbeat = time.Now()
+}
+
+// parseHeartbeat returns the leader and time of the heartbeat record called name in records
+func parseHeartbeat(records []libdns.Record, zone, name string) (leader string, at time.Time, ok bool) {
+	for _, record := range records {
+		rr := record.RR()
+		if rr.Type != "TXT" || !sameName(rr.Name, name, zone) {
+			continue
+		}
+
+		for _, field := range strings.Split(strings.Trim(rr.Data, `"`), ",") {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "sentinel/leader":
+				leader = value
+			case "sentinel/heartbeat":
+				if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+					at, ok = time.Unix(unix, 0), true
+				}
+			}
+		}
+		return leader, at, ok
+	}
+	return "", time.Time{}, false
+}
+
+// coordinate points all records to the fallback IP once the leader heartbeat
+// is older than the fallback delay. Records are left alone while it is fresh,
+// the leader takes them back on its next reconcile after recovering.
+func (s *Sentinel) coordinate(ctx context.Context) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.recordStatus(false, err) }()
+
+	zone, name := s.heartbeatRecord()
+	records, err := s.getRecords(ctx, zone, true)
+	if err != nil {
+		reconcileErr := s.reconcileError("get records", zone, "", err)
+		logger.Errorf("Could not read leader heartbeat: %v", reconcileErr)
+		return reconcileErr
+	}
+
+	leader, at, ok := parseHeartbeat(records, zone, name)
+	switch {
+	case ok && time.Since(at) < s.Config.FallbackAfter:
+		logger.Debugf("Leader %s sent a heartbeat %s ago", leader, time.Since(at).Round(time.Second))
+		s.ready.Store(true)
+		return nil
+	case ok:
+		logger.Warnf("No heartbeat from a leader for %s (last from %s), falling back to %s", time.Since(at).Round(time.Second), leader, s.Config.FallbackIP)
+	default:
+		logger.Warnf("No leader heartbeat found in TXT record %s, falling back to %s", name, s.Config.FallbackIP)
+	}
+
+	var errs []error
+	for _, z := range s.zones() {
+		if err := s.applyFallback(ctx, z); err != nil {
+			errs = append(errs, fmt.Errorf("zone %s: %w", z.zone(), err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	s.ready.Store(true)
+	return nil
+}
+
+// applyFallback points the records of z to the fallback IP and removes their
+// addresses of the other family, which would still lead to the lost leader
+func (s *Sentinel) applyFallback(ctx context.Context, z ZoneRecords) error {
+	zone := z.zone()
+
+	ip, err := netip.ParseAddr(s.Config.FallbackIP)
+	if err != nil {
+		return fmt.Errorf("invalid fallback IP %q: %v", s.Config.FallbackIP, err)
+	}
+	ip = ip.Unmap()
+	otherType := "AAAA"
+	if ip.Is6() {
+		otherType = "A"
+	}
+
+	records, err := s.getRecords(ctx, zone, false)
+	if err != nil {
+		reconcileErr := s.reconcileError("get records", zone, "", err)
+		logger.Errorf("Could not get DNS records: %v", reconcileErr)
+		return reconcileErr
+	}
+
+	failed := 0
+	var other []libdns.Record
+	for _, name := range z.recordNames() {
+		desired := libdns.Address{Name: name, IP: ip, TTL: s.recordTTL(z, name)}
+		if s.updateRecord(ctx, zone, records, desired) == recordFailed {
+			failed++
+		}
+
+		for _, record := range records {
+			rr := record.RR()
+			if rr.Type == otherType && sameName(rr.Name, name, zone) {
+				other = append(other, record)
+			}
+		}
+	}
+
+	if err := s.deleteRecords(ctx, zone, other); err != nil {
+		failed++
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d records could not be pointed to the fallback IP", failed)
+	}
+	return nil
+}
+
+// coordinatorLoop runs CheckAndUpdateDNS, which coordinates in this mode, four
+// times per fallback delay
+func (s *Sentinel) coordinatorLoop(ctx context.Context) {
+	timer := time.NewTimer(s.jitter(s.Config.FallbackAfter / 4))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			_ = s.CheckAndUpdateDNS(ctx)
+			timer.Reset(s.jitter(s.Config.FallbackAfter / 4))
+		}
+	}
+}
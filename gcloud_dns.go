@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/googleclouddns"
+	"github.com/libdns/libdns"
+)
+
+// GcloudDnsClient wraps the Cloud DNS provider to report zones missing from
+// the project as ErrZoneNotFound
+type GcloudDnsClient struct {
+	*googleclouddns.Provider
+}
+
+// GetRecords lists all records in zone
+func (c *GcloudDnsClient) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	records, err := c.Provider.GetRecords(ctx, zone)
+	return records, c.explain(zone, err)
+}
+
+// AppendRecords adds records to zone
+func (c *GcloudDnsClient) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.AppendRecords(ctx, zone, records)
+	return records, c.explain(zone, err)
+}
+
+// SetRecords replaces the records of zone sharing name and type with records
+func (c *GcloudDnsClient) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.SetRecords(ctx, zone, records)
+	return records, c.explain(zone, err)
+}
+
+// DeleteRecords removes records from zone
+func (c *GcloudDnsClient) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.DeleteRecords(ctx, zone, records)
+	return records, c.explain(zone, err)
+}
+
+// explain wraps the error the provider returns for zones without a public
+// managed zone in the project
+func (c *GcloudDnsClient) explain(zone string, err error) error {
+	if err != nil && strings.Contains(err.Error(), "unable to find Google") {
+		return fmt.Errorf("no public managed zone for %s found in project %s: %w", zone, c.Project, ErrZoneNotFound)
+	}
+	return err
+}
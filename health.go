@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// startHealthServer serves the liveness, readiness and status endpoints on addr in the background
+func (s *Sentinel) startHealthServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/status", s.handleStatus)
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		logger.Infof("Serving health checks on %s", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Health server error: %v", err)
+		}
+	}()
+
+	return server
+}
+
+// handleHealthz reports whether the orchestration adapter and DNS client are initialized
+func (s *Sentinel) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	if !s.initialized.Load() {
+		http.Error(w, "not initialized", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ok"))
+}
+
+// handleReadyz reports whether the first DNS check completed successfully
+func (s *Sentinel) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	if !s.ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ok"))
+}
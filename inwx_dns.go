@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/base32"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/libdns/inwx"
+	"github.com/libdns/libdns"
+)
+
+// inwxAuthError matches the INWX result codes of a failed login or unlock,
+// 2200 "Authentication error" and 2201 "Authorization failed"
+var inwxAuthError = regexp.MustCompile(`\(220[01]\)`)
+
+// inwxNotFoundError matches INWX result code 2303 "Object does not exist",
+// returned for domains that are not in the account
+var inwxNotFoundError = regexp.MustCompile(`\(2303\)`)
+
+// InwxDnsClient wraps the INWX provider to explain failed logins of accounts
+// with two-factor authentication
+type InwxDnsClient struct {
+	*inwx.Provider
+}
+
+// GetRecords lists all records in zone
+func (c *InwxDnsClient) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	records, err := c.Provider.GetRecords(ctx, zone)
+	if err != nil && inwxNotFoundError.MatchString(err.Error()) {
+		return nil, fmt.Errorf("%w: %w", ErrZoneNotFound, err)
+	}
+	return records, c.explain(err)
+}
+
+// AppendRecords adds records to zone
+func (c *InwxDnsClient) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.AppendRecords(ctx, zone, records)
+	return records, c.explain(err)
+}
+
+// SetRecords replaces the records of zone sharing name and type with records
+func (c *InwxDnsClient) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.SetRecords(ctx, zone, records)
+	return records, c.explain(err)
+}
+
+// DeleteRecords removes records from zone
+func (c *InwxDnsClient) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.DeleteRecords(ctx, zone, records)
+	return records, c.explain(err)
+}
+
+// explain adds a hint to authentication errors. Accounts with mandatory 2FA
+// are only unlocked with a TOTP code, which the provider derives from
+// SharedSecret, so a missing secret shows up as a failed login.
+func (c *InwxDnsClient) explain(err error) error {
+	if err == nil || !inwxAuthError.MatchString(err.Error()) {
+		return err
+	}
+	if c.SharedSecret == "" {
+		return fmt.Errorf("%w (if the INWX account has two-factor authentication enabled, set SENTINEL_INWX_TOTP_SECRET)", err)
+	}
+	return fmt.Errorf("%w (check SENTINEL_INWX_USER, SENTINEL_INWX_PASSWORD and SENTINEL_INWX_TOTP_SECRET)", err)
+}
+
+// normalizeTOTPSecret removes the spaces and padding INWX shows the 2FA secret
+// with and checks that the result is valid base32
+func normalizeTOTPSecret(secret string) (string, error) {
+	secret = strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+	secret = strings.TrimRight(secret, "=")
+	if _, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret); err != nil {
+		return "", fmt.Errorf("INWX_TOTP_SECRET is not a valid base32 secret: %v", err)
+	}
+	return secret, nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// Values of SENTINEL_IP_SOURCE
+const (
+	IPSourceOrchestrator      = "orchestrator"       // the adapter's default lookup, e.g. label then node address
+	IPSourceOrchestratorLabel = "orchestrator-label" // the public IP labels of the node only
+	IPSourceNodeExternalIP    = "node-external-ip"   // the address the orchestrator reports for the node
+	IPSourceExternalHTTP      = "external-http"      // the egress IP reported by an HTTP echo service
+	IPSourceExternal          = "external"           // alias of external-http
+	IPSourceStatic            = "static"             // SENTINEL_SERVER_IP and SENTINEL_SERVER_IPV6
+)
+
+// IPSource discovers the public addresses of the node Sentinel runs on,
+// independent of the orchestration adapter used for leader election
+type IPSource interface {
+	// PublicIPs returns the addresses per IP family (IPFamilyIPv4, IPFamilyIPv6)
+	PublicIPs() (map[string]string, error)
+}
+
+// NodeExternalIPReader is implemented by adapters that know the address of the
+// node Sentinel runs on, e.g. the Kubernetes ExternalIP or the Docker Swarm node address
+type NodeExternalIPReader interface {
+	GetNodeExternalIPs() (map[string]string, error)
+}
+
+// ipSources returns the IP sources to try in order: SENTINEL_IP_SOURCE_ORDER
+// if set, otherwise the single SENTINEL_IP_SOURCE
+func (c *Config) ipSources() []string {
+	if len(c.IPSourceOrder) > 0 {
+		return c.IPSourceOrder
+	}
+	return []string{c.IPSource}
+}
+
+// ipSourceNames describes the IP sources for the log
+func (c *Config) ipSourceNames() string {
+	return strings.Join(c.ipSources(), ",")
+}
+
+// newIPSource creates the IP source selected by SENTINEL_IP_SOURCE, or one
+// trying each of SENTINEL_IP_SOURCE_ORDER in turn
+func newIPSource(config *Config, orchestration OrchestrationAdapter) (IPSource, error) {
+	if len(config.IPSourceOrder) == 0 {
+		return newNamedIPSource(config.IPSource, config, orchestration)
+	}
+
+	// Internal addresses may still be mapped to public ones by SENTINEL_IP_TRANSFORM
+	ordered := &orderedIPSource{allowPrivateIP: config.AllowPrivateIP || config.IPTransform != ""}
+	for _, name := range config.IPSourceOrder {
+		source, err := newNamedIPSource(name, config, orchestration)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		ordered.names = append(ordered.names, name)
+		ordered.sources = append(ordered.sources, source)
+	}
+	return ordered, nil
+}
+
+// newNamedIPSource creates the IP source called name
+func newNamedIPSource(name string, config *Config, orchestration OrchestrationAdapter) (IPSource, error) {
+	switch name {
+	case IPSourceStatic:
+		return &staticIPSource{ipv4: config.ServerIP, ipv6: config.ServerIPv6}, nil
+	case IPSourceExternalHTTP, IPSourceExternal:
+		return &externalHTTPIPSource{echoURL: config.IPEchoURL}, nil
+	case IPSourceOrchestratorLabel:
+		reader, ok := orchestration.(NodeLabelReader)
+		if !ok {
+			return nil, fmt.Errorf("orchestration %s cannot read node labels", config.OrchestrationType)
+		}
+		return &labelIPSource{reader: reader, labels: []string{config.PublicIPLabel, config.PublicIPv6Label}}, nil
+	case IPSourceNodeExternalIP:
+		reader, ok := orchestration.(NodeExternalIPReader)
+		if !ok {
+			return nil, fmt.Errorf("orchestration %s cannot report node addresses", config.OrchestrationType)
+		}
+		return &nodeExternalIPSource{reader: reader}, nil
+	default:
+		return &orchestratorIPSource{orchestration: orchestration}, nil
+	}
+}
+
+// orchestratorIPSource uses the adapter's own lookup of the node IP
+type orchestratorIPSource struct {
+	orchestration OrchestrationAdapter
+}
+
+// PublicIPs returns the addresses of both families from adapters that report
+// them, and the single public IP of the node otherwise
+func (o *orchestratorIPSource) PublicIPs() (map[string]string, error) {
+	if reader, ok := o.orchestration.(NodeAddressReader); ok {
+		return reader.GetNodePublicIPs()
+	}
+
+	publicIP, err := o.orchestration.GetNodePublicIP()
+	if err != nil {
+		return nil, err
+	}
+	ips := make(map[string]string)
+	addIPByFamily(ips, publicIP)
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("node public IP %q is not an IP address", publicIP)
+	}
+	return ips, nil
+}
+
+// labelIPSource reads the addresses from labels (Nomad: meta) of the node
+type labelIPSource struct {
+	reader NodeLabelReader
+	labels []string
+}
+
+// PublicIPs returns the addresses found in the labels, missing labels are skipped
+func (l *labelIPSource) PublicIPs() (map[string]string, error) {
+	ips := make(map[string]string)
+	var lastErr error
+	for _, label := range l.labels {
+		if label == "" {
+			continue
+		}
+		value, err := l.reader.GetCurrentNodeLabel(label)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		addIPByFamily(ips, value)
+	}
+
+	if len(ips) == 0 {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, fmt.Errorf("no IP address found in node labels")
+	}
+	return ips, nil
+}
+
+// nodeExternalIPSource uses the address the orchestrator reports for the node
+type nodeExternalIPSource struct {
+	reader NodeExternalIPReader
+}
+
+// PublicIPs returns the node addresses per IP family
+func (n *nodeExternalIPSource) PublicIPs() (map[string]string, error) {
+	return n.reader.GetNodeExternalIPs()
+}
+
+// externalHTTPIPSource asks an HTTP echo service for the egress IP
+type externalHTTPIPSource struct {
+	echoURL string
+}
+
+// PublicIPs returns the egress IP under its family
+func (e *externalHTTPIPSource) PublicIPs() (map[string]string, error) {
+	publicIP, err := discoverExternalIP(e.echoURL)
+	if err != nil {
+		return nil, err
+	}
+	ips := make(map[string]string)
+	addIPByFamily(ips, publicIP)
+	return ips, nil
+}
+
+// staticIPSource returns the configured addresses
+type staticIPSource struct {
+	ipv4, ipv6 string
+}
+
+// PublicIPs returns the configured addresses per IP family
+func (s *staticIPSource) PublicIPs() (map[string]string, error) {
+	ips := make(map[string]string)
+	addIPByFamily(ips, s.ipv4)
+	addIPByFamily(ips, s.ipv6)
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no static IP configured (set SENTINEL_SERVER_IP or SENTINEL_SERVER_IPV6)")
+	}
+	return ips, nil
+}
+
+// orderedIPSource tries several IP sources in order and uses the first that
+// yields a public address
+type orderedIPSource struct {
+	names          []string
+	sources        []IPSource
+	allowPrivateIP bool
+
+	// mu guards used, the source the last addresses came from
+	mu   sync.Mutex
+	used string
+}
+
+// PublicIPs returns the public addresses of the first source that has any.
+// Errors and private addresses only move on to the next source.
+func (o *orderedIPSource) PublicIPs() (map[string]string, error) {
+	var failures []string
+	for i, source := range o.sources {
+		name := o.names[i]
+		found, err := source.PublicIPs()
+		if err != nil {
+			logger.Debugf("IP source %s failed: %v", name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+
+		ips := make(map[string]string)
+		for family, raw := range found {
+			if ip, err := netip.ParseAddr(raw); err == nil && (o.allowPrivateIP || isPublicIP(ip)) {
+				ips[family] = ip.Unmap().String()
+			}
+		}
+		if len(ips) == 0 {
+			logger.Debugf("IP source %s returned no public IP", name)
+			failures = append(failures, fmt.Sprintf("%s: no public IP", name))
+			continue
+		}
+
+		o.mu.Lock()
+		if o.used != name {
+			logger.Infof("Using public IP from source %s", name)
+			o.used = name
+		}
+		o.mu.Unlock()
+		return ips, nil
+	}
+	return nil, fmt.Errorf("no IP source yielded a public IP (%s)", strings.Join(failures, "; "))
+}
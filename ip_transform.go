@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ipTransformExecPrefix marks SENTINEL_IP_TRANSFORM values naming a program
+const ipTransformExecPrefix = "exec:"
+
+// ipTransformCacheTTL is how long the result of the transform program is
+// reused, as the address is needed once per record and reconcile
+const ipTransformCacheTTL = time.Minute
+
+// ipMapping translates addresses in from to the same host part in to
+type ipMapping struct {
+	from, to netip.Prefix
+}
+
+// ipTransform rewrites the server IP before it is published, e.g. through a
+// NAT table. Addresses without a matching mapping are published unchanged.
+type ipTransform struct {
+	mappings []ipMapping
+	command  string
+
+	// cache holds the recent results of command
+	mu    sync.Mutex
+	cache map[netip.Addr]cachedTransform
+}
+
+// cachedTransform is a result of the transform program
+type cachedTransform struct {
+	ip      netip.Addr
+	fetched time.Time
+}
+
+// parseIPTransform parses SENTINEL_IP_TRANSFORM: either "exec:<program>", which
+// is called with the IP as its argument and prints the address to publish, or
+// a comma separated list of "from=to" mappings of addresses or equally sized
+// prefixes, e.g. "10.0.0.0/24=203.0.113.0/24" maps 10.0.0.7 to 203.0.113.7.
+// It returns nil for an empty value.
+func parseIPTransform(value string) (*ipTransform, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	if command, ok := strings.CutPrefix(value, ipTransformExecPrefix); ok {
+		if strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("IP transform %q names no program", value)
+		}
+		return &ipTransform{command: strings.TrimSpace(command), cache: make(map[netip.Addr]cachedTransform)}, nil
+	}
+
+	transform := &ipTransform{}
+	for _, entry := range splitList(value) {
+		rawFrom, rawTo, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("IP transform entry %q is not of the form from=to", entry)
+		}
+		from, err := parseIPOrPrefix(strings.TrimSpace(rawFrom))
+		if err != nil {
+			return nil, fmt.Errorf("IP transform entry %q: %v", entry, err)
+		}
+		to, err := parseIPOrPrefix(strings.TrimSpace(rawTo))
+		if err != nil {
+			return nil, fmt.Errorf("IP transform entry %q: %v", entry, err)
+		}
+
+		// Single addresses may map across families, prefixes keep the host part
+		if !from.IsSingleIP() || !to.IsSingleIP() {
+			if from.Addr().Is4() != to.Addr().Is4() || from.Bits() != to.Bits() {
+				return nil, fmt.Errorf("IP transform entry %q maps prefixes of different size", entry)
+			}
+		}
+		transform.mappings = append(transform.mappings, ipMapping{from: from, to: to})
+	}
+	return transform, nil
+}
+
+// parseIPOrPrefix parses an address as a single-address prefix or a prefix
+func parseIPOrPrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	ip, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip = ip.Unmap()
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+// apply returns the address to publish for ip
+func (t *ipTransform) apply(ip netip.Addr) (netip.Addr, error) {
+	if t.command != "" {
+		return t.run(ip)
+	}
+
+	for _, mapping := range t.mappings {
+		if !mapping.from.Contains(ip) {
+			continue
+		}
+		if mapping.from.IsSingleIP() {
+			return mapping.to.Addr(), nil
+		}
+		return mapHostPart(ip, mapping.to), nil
+	}
+	return ip, nil
+}
+
+// mapHostPart combines the network part of to with the host part of ip
+func mapHostPart(ip netip.Addr, to netip.Prefix) netip.Addr {
+	addr := ip.As16()
+	network := to.Addr().As16()
+
+	// Within the 16 byte form IPv4 addresses start at bit 96
+	bits := to.Bits()
+	if to.Addr().Is4() {
+		bits += 96
+	}
+	for i := range addr {
+		switch {
+		case bits >= 8:
+			addr[i] = network[i]
+			bits -= 8
+		case bits > 0:
+			mask := byte(0xff) << (8 - bits)
+			addr[i] = network[i]&mask | addr[i]&^mask
+			bits = 0
+		}
+	}
+	return netip.AddrFrom16(addr).Unmap()
+}
+
+// run calls the transform program with ip and parses its output, results are
+// reused for ipTransformCacheTTL
+func (t *ipTransform) run(ip netip.Addr) (netip.Addr, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if cached, ok := t.cache[ip]; ok && time.Since(cached.fetched) < ipTransformCacheTTL {
+		return cached.ip, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.command, ip.String())
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return netip.Addr{}, fmt.Errorf("IP transform %s failed: %v: %s", t.command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	transformed, err := netip.ParseAddr(strings.TrimSpace(stdout.String()))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("IP transform %s returned no IP address: %v", t.command, err)
+	}
+	transformed = transformed.Unmap()
+
+	t.cache[ip] = cachedTransform{ip: transformed, fetched: time.Now()}
+	return transformed, nil
+}
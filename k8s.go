@@ -3,28 +3,80 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	authorizationv1 "k8s.io/api/authorization/v1"
 	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	coordinationlisters "k8s.io/client-go/listers/coordination/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 )
 
+const K8sLeaderModeControllerManager = "controller-manager"
+const K8sLeaderModeSelf = "self"
+
+// Lease followed in controller-manager mode unless configured otherwise. Managed
+// clusters (EKS/GKE/AKS) hide it from workloads, so another lease has to be used there.
+const DefaultK8sControllerManagerLeaseNamespace = "kube-system"
+const DefaultK8sControllerManagerLeaseName = "kube-controller-manager"
+
+// Lease elected among the Sentinel instances in self mode unless configured otherwise
+const DefaultK8sSelfLeaseNamespace = "sentinel"
+const DefaultK8sSelfLeaseName = "sentinel"
+
+// k8sCacheSyncTimeout bounds the wait for the informer caches on first use
+const k8sCacheSyncTimeout = 30 * time.Second
+
 // K8sClient handles communication with the Kubernetes API
 type K8sClient struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
+
+	// leaderMode selects whether leadership follows the kube-controller-manager
+	// lease or a lease elected among the Sentinel instances themselves
+	leaderMode     string
+	leaseName      string
+	leaseNamespace string
+
+	// publicIPLabel is the node label holding the public IP, publicIPv6Label
+	// an optional label holding its IPv6 address
+	publicIPLabel   string
+	publicIPv6Label string
+
+	// isLeader holds the result of our own election in self mode
+	isLeader atomic.Bool
+
+	// The lease and node informers keep the watched objects in a local cache,
+	// so IsLeader and the node lookups do not hit the API server. Only the
+	// node of this instance is watched, all nodes in multi mode.
+	factories     []informers.SharedInformerFactory
+	leaseInformer cache.SharedIndexInformer // nil in self mode
+	nodeInformer  cache.SharedIndexInformer
+	leases        coordinationlisters.LeaseLister
+	nodes         corelisters.NodeLister
+	allNodes      bool
+	startOnce     sync.Once
+	stopOnce      sync.Once
+	stopCh        chan struct{}
 }
 
 // NewK8sClient creates a new Kubernetes client
-func NewK8sClient() (*K8sClient, error) {
-	kubeconfig := os.Getenv("KUBECONFIG")
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+func NewK8sClient(sentinelConfig *Config) (*K8sClient, error) {
+	config, err := k8sRestConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -34,7 +86,108 @@ func NewK8sClient() (*K8sClient, error) {
 		return nil, err
 	}
 
-	return &K8sClient{clientset: clientset}, nil
+	return NewK8sClientWithClientset(sentinelConfig, clientset), nil
+}
+
+// NewK8sClientWithClientset creates a Kubernetes client using clientset, e.g. a
+// fake clientset in tests
+func NewK8sClientWithClientset(sentinelConfig *Config, clientset kubernetes.Interface) *K8sClient {
+	k := &K8sClient{
+		clientset:       clientset,
+		leaderMode:      sentinelConfig.K8sLeaderMode,
+		leaseName:       sentinelConfig.K8sLeaseName,
+		leaseNamespace:  sentinelConfig.K8sLeaseNamespace,
+		publicIPLabel:   sentinelConfig.PublicIPLabel,
+		publicIPv6Label: sentinelConfig.PublicIPv6Label,
+		allNodes:        sentinelConfig.Mode == ModeMulti,
+		stopCh:          make(chan struct{}),
+	}
+
+	nodeOptions := []informers.SharedInformerOption{}
+	if nodeName := os.Getenv("NODE_NAME"); nodeName != "" && !k.allNodes {
+		nodeOptions = append(nodeOptions, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", nodeName).String()
+		}))
+	}
+	nodeFactory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, nodeOptions...)
+	k.nodeInformer = nodeFactory.Core().V1().Nodes().Informer()
+	k.nodes = nodeFactory.Core().V1().Nodes().Lister()
+	k.factories = append(k.factories, nodeFactory)
+
+	// Our own election reads and writes its lease itself
+	if k.leaderMode != K8sLeaderModeSelf {
+		leaseFactory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+			informers.WithNamespace(k.leaseNamespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = fields.OneTermEqualSelector("metadata.name", k.leaseName).String()
+			}))
+		k.leaseInformer = leaseFactory.Coordination().V1().Leases().Informer()
+		k.leases = leaseFactory.Coordination().V1().Leases().Lister()
+		k.factories = append(k.factories, leaseFactory)
+	}
+
+	return k
+}
+
+// startInformers starts the informers on first use and waits for their caches
+// to be filled
+func (k *K8sClient) startInformers() error {
+	k.startOnce.Do(func() {
+		for _, factory := range k.factories {
+			factory.Start(k.stopCh)
+		}
+	})
+
+	synced := []cache.InformerSynced{k.nodeInformer.HasSynced}
+	if k.leaseInformer != nil {
+		synced = append(synced, k.leaseInformer.HasSynced)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), k8sCacheSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return fmt.Errorf("Kubernetes informer caches not synced within %s", k8sCacheSyncTimeout)
+	}
+	return nil
+}
+
+// stopInformers stops the informers, after which the caches no longer change
+func (k *K8sClient) stopInformers() {
+	k.stopOnce.Do(func() { close(k.stopCh) })
+}
+
+// currentNode returns the node Sentinel runs on from the informer cache
+func (k *K8sClient) currentNode() (*v1.Node, error) {
+	nodeName, err := k.GetNodeName()
+	if err != nil {
+		return nil, err
+	}
+	if err := k.startInformers(); err != nil {
+		return nil, err
+	}
+
+	node, err := k.nodes.Get(nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting node: %v", err)
+	}
+	return node, nil
+}
+
+// k8sRestConfig uses the service account of the pod when running in-cluster,
+// otherwise KUBECONFIG or the default kubeconfig in the home directory
+func k8sRestConfig() (*rest.Config, error) {
+	config, err := rest.InClusterConfig()
+	if err == nil {
+		return config, nil
+	}
+	logger.Debugf("Not running in-cluster, using kubeconfig: %v", err)
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading kubeconfig: %v", err)
+	}
+	return config, nil
 }
 
 // GetNodeName retrieves the current node name from environment variable
@@ -48,90 +201,256 @@ func (k *K8sClient) GetNodeName() (string, error) {
 
 // GetNodePublicIP retrieves the public IP address from node
 func (k *K8sClient) GetNodePublicIP() (string, error) {
-	nodeName, err := k.GetNodeName()
+	node, err := k.currentNode()
+	if err != nil {
+		return "", err
+	}
+
+	publicIP, exists := nodePublicIP(node, k.publicIPLabel)
+	if !exists {
+		return "", fmt.Errorf("no external IP found for node %s (neither in addresses nor in %s label)", node.Name, k.publicIPLabel)
+	}
+
+	return publicIP, nil
+}
+
+// GetNodePublicIPs returns the public addresses of the current node per IP
+// family, taken from the public IP labels first and its ExternalIP addresses
+func (k *K8sClient) GetNodePublicIPs() (map[string]string, error) {
+	node, err := k.currentNode()
+	if err != nil {
+		return nil, err
+	}
+
+	ips := k.nodePublicIPs(node)
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no external IP found for node %s (neither in addresses nor in %s label)", node.Name, k.publicIPLabel)
+	}
+	return ips, nil
+}
+
+// nodePublicIPs returns the public addresses of node per IP family, taken from
+// the public IP labels first and its ExternalIP addresses
+func (k *K8sClient) nodePublicIPs(node *v1.Node) map[string]string {
+	ips := make(map[string]string)
+	addIPByFamily(ips, node.Labels[k.publicIPLabel])
+	if k.publicIPv6Label != "" {
+		addIPByFamily(ips, node.Labels[k.publicIPv6Label])
+	}
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeExternalIP {
+			addIPByFamily(ips, address.Address)
+		}
+	}
+	return ips
+}
+
+// GetNodeExternalIPs returns the ExternalIP addresses of the current node per IP family
+func (k *K8sClient) GetNodeExternalIPs() (map[string]string, error) {
+	node, err := k.currentNode()
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make(map[string]string)
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeExternalIP {
+			addIPByFamily(ips, address.Address)
+		}
+	}
+
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no external IP found for node %s", node.Name)
+	}
+	return ips, nil
+}
+
+// GetCurrentNodeLabel retrieves a specific label from the current node
+func (k *K8sClient) GetCurrentNodeLabel(labelName string) (string, error) {
+	node, err := k.currentNode()
 	if err != nil {
 		return "", err
 	}
 
-	node, err := k.clientset.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	value, exists := node.Labels[labelName]
+	if !exists {
+		return "", fmt.Errorf("label %s not found on node %s", labelName, node.Name)
+	}
+
+	return value, nil
+}
+
+// IsNodeReady checks whether the current node reports the Ready condition
+func (k *K8sClient) IsNodeReady() (bool, error) {
+	node, err := k.currentNode()
+	if err != nil {
+		return false, err
+	}
+
+	return nodeReady(node), nil
+}
+
+// ListReadyNodeIPs returns the public addresses of all ready nodes per IP
+// family, nodes without a public IP are skipped. The nodes come from the cache
+// in multi mode, which watches all of them.
+func (k *K8sClient) ListReadyNodeIPs(ctx context.Context) ([]map[string]string, error) {
+	nodes, err := k.listNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeIPs []map[string]string
+	for _, node := range nodes {
+		if !nodeReady(node) {
+			continue
+		}
+
+		ips := k.nodePublicIPs(node)
+		if len(ips) == 0 {
+			logger.Warnf("Node %s has no external IP, skipping it", node.Name)
+			continue
+		}
+		nodeIPs = append(nodeIPs, ips)
+	}
+
+	return nodeIPs, nil
+}
+
+// listNodes returns all nodes sorted by name
+func (k *K8sClient) listNodes(ctx context.Context) ([]*v1.Node, error) {
+	if !k.allNodes {
+		list, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error listing nodes: %v", err)
+		}
+		nodes := make([]*v1.Node, len(list.Items))
+		for i := range list.Items {
+			nodes[i] = &list.Items[i]
+		}
+		return nodes, nil
+	}
+
+	if err := k.startInformers(); err != nil {
+		return nil, err
+	}
+	nodes, err := k.nodes.List(labels.Everything())
 	if err != nil {
-		return "", fmt.Errorf("error getting node: %v", err)
+		return nil, fmt.Errorf("error listing nodes: %v", err)
 	}
+	slices.SortFunc(nodes, func(a, b *v1.Node) int { return strings.Compare(a.Name, b.Name) })
+	return nodes, nil
+}
+
+// nodeReady reports whether the Ready condition of node is true
+func nodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
 
+// nodePublicIP returns the label of node named labelName, or its first ExternalIP address
+func nodePublicIP(node *v1.Node, labelName string) (string, bool) {
 	// Try to get from label
-	publicIP, exists := node.Labels["public_ip"]
+	publicIP, exists := node.Labels[labelName]
 	if exists {
-		return publicIP, nil
+		return publicIP, true
 	}
 
 	// Look for ExternalIP in node addresses
 	for _, address := range node.Status.Addresses {
 		if address.Type == v1.NodeExternalIP {
-			return address.Address, nil
+			return address.Address, true
 		}
 	}
 
-	return "", fmt.Errorf("no external IP found for node %s (neither in addresses nor in public_ip label)", nodeName)
+	return "", false
 }
 
-// IsLeader checks if the current node is the leader by examining controller manager lease
-func (k *K8sClient) IsLeader() bool {
+// IsLeader checks if the current node is the leader, either by our own election
+// or by examining the controller manager lease. An error means leadership could
+// not be determined.
+func (k *K8sClient) IsLeader(_ context.Context) (bool, error) {
+	if k.leaderMode == K8sLeaderModeSelf {
+		return k.isLeader.Load(), nil
+	}
+
 	nodeName, err := k.GetNodeName()
 	if err != nil {
-		log.Printf("Error getting node name: %v", err)
-		return false
+		return false, fmt.Errorf("error getting node name: %v", err)
 	}
 
-	lease, err := k.clientset.CoordinationV1().Leases("kube-system").Get(context.TODO(), "kube-controller-manager", metav1.GetOptions{})
+	if err := k.startInformers(); err != nil {
+		return false, err
+	}
+	lease, err := k.leases.Leases(k.leaseNamespace).Get(k.leaseName)
 	if err != nil {
-		log.Printf("Error getting kube-controller-manager lease: %v", err)
-		return false
+		return false, fmt.Errorf("error getting lease %s/%s: %v", k.leaseNamespace, k.leaseName, err)
 	}
 
 	if lease.Spec.HolderIdentity == nil {
-		log.Println("No holder identity found in lease")
-		return false
+		logger.Warnf("No holder identity found in lease")
+		return false, nil
 	}
 
-	holderIdentity := *lease.Spec.HolderIdentity
+	return holderMatchesNode(*lease.Spec.HolderIdentity, nodeName), nil
+}
+
+// holderMatchesNode reports whether a lease holder identity belongs to nodeName.
+// kube-controller-manager uses "<hostname>_<uuid>", where the hostname may be the
+// FQDN while the node is registered under its short name, or the other way round.
+// Two different FQDNs never match, even if their first labels are equal.
+func holderMatchesNode(holderIdentity, nodeName string) bool {
+	host := holderIdentity
+	if i := strings.LastIndex(holderIdentity, "_"); i >= 0 {
+		host = holderIdentity[:i]
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	node := strings.ToLower(strings.TrimSuffix(nodeName, "."))
+	if host == "" || node == "" {
+		return false
+	}
 
-	// Check if the holder identity starts with our node name followed by underscore
-	// Format is typically: nodename_uuid
-	expectedPrefix := nodeName + "_"
-	return strings.HasPrefix(holderIdentity, expectedPrefix)
+	shortHost, _, _ := strings.Cut(host, ".")
+	shortNode, _, _ := strings.Cut(node, ".")
+	return host == node || shortHost == node || host == shortNode
 }
 
-// WatchEvents watches for changes in leader election leases
-func (k *K8sClient) WatchEvents(callback func()) {
-	listWatcher := cache.NewListWatchFromClient(
-		k.clientset.CoordinationV1().RESTClient(),
-		"leases",
-		"kube-system",
-		fields.Everything(),
-	)
+// WatchEvents watches for changes in leader election leases until ctx is cancelled
+func (k *K8sClient) WatchEvents(ctx context.Context, callback func()) {
+	defer k.stopInformers()
 
-	informer := cache.NewSharedInformer(
-		listWatcher,
-		&coordinationv1.Lease{},
-		0,
-	)
+	if err := k.watchNode(callback); err != nil {
+		logger.Errorf("Error adding node event handler: %v", err)
+	}
+
+	if k.leaderMode == K8sLeaderModeSelf {
+		if err := k.startInformers(); err != nil {
+			logger.Errorf("Error starting informers: %v", err)
+		}
+		k.runLeaderElection(ctx, callback)
+		return
+	}
 
-	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	_, err := k.leaseInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			oldLease, ok := oldObj.(*coordinationv1.Lease)
 			if !ok {
-				log.Printf("Error: oldObj is not a Lease object")
+				logger.Errorf("Error: oldObj is not a Lease object")
 				return
 			}
 
 			newLease, ok := newObj.(*coordinationv1.Lease)
 			if !ok {
-				log.Printf("Error: newObj is not a Lease object")
+				logger.Errorf("Error: newObj is not a Lease object")
 				return
 			}
 
-			// Watch for controller manager lease changes
-			if oldLease.Name == "kube-controller-manager" {
+			// Watch for holder changes of the leader lease
+			if oldLease.Name == k.leaseName {
 				oldHolder := ""
 				newHolder := ""
 
@@ -143,26 +462,175 @@ func (k *K8sClient) WatchEvents(callback func()) {
 				}
 
 				if oldHolder != newHolder {
-					log.Printf("Leader change detected: %s -> %s", oldHolder, newHolder)
+					logger.Infof("Leader change detected: %s -> %s", oldHolder, newHolder)
 					callback()
 				}
 			}
 		},
 	})
 	if err != nil {
-		log.Printf("Error adding event handler: %v", err)
+		logger.Errorf("Error adding event handler: %v", err)
 		return
 	}
 
-	stopCh := make(chan struct{})
-	defer close(stopCh)
-
-	go informer.Run(stopCh)
+	if err := k.startInformers(); err != nil {
+		logger.Errorf("Error starting informers: %v", err)
+	}
 
-	// Wait forever
-	select {}
+	// Wait until shutdown, the deferred stop ends the informers
+	<-ctx.Done()
 }
 
+// GetConfigurationErrors checks the prerequisites for running on Kubernetes, so a
+// missing RBAC rule is reported at startup instead of as a silent false from IsLeader
 func (k *K8sClient) GetConfigurationErrors() []string {
-	return []string{}
+	var errs []string
+
+	if _, err := k.GetNodeName(); err != nil {
+		errs = append(errs, "NODE_NAME is not set, pass it via the downward API (fieldRef: spec.nodeName)")
+	}
+
+	if _, err := k.clientset.Discovery().ServerVersion(); err != nil {
+		return append(errs, fmt.Sprintf("Kubernetes API server not reachable: %v", err))
+	}
+
+	// The informers list and watch, our own election gets and writes its lease
+	required := []authorizationv1.ResourceAttributes{
+		{Resource: "nodes", Verb: "list"},
+		{Resource: "nodes", Verb: "watch"},
+	}
+	if k.leaderMode == K8sLeaderModeSelf {
+		required = append(required,
+			authorizationv1.ResourceAttributes{Group: "coordination.k8s.io", Resource: "leases", Verb: "get", Namespace: k.leaseNamespace, Name: k.leaseName},
+			authorizationv1.ResourceAttributes{Group: "coordination.k8s.io", Resource: "leases", Verb: "create", Namespace: k.leaseNamespace},
+			authorizationv1.ResourceAttributes{Group: "coordination.k8s.io", Resource: "leases", Verb: "update", Namespace: k.leaseNamespace, Name: k.leaseName},
+		)
+	} else {
+		required = append(required,
+			authorizationv1.ResourceAttributes{Group: "coordination.k8s.io", Resource: "leases", Verb: "list", Namespace: k.leaseNamespace},
+			authorizationv1.ResourceAttributes{Group: "coordination.k8s.io", Resource: "leases", Verb: "watch", Namespace: k.leaseNamespace},
+		)
+	}
+
+	for _, attributes := range required {
+		allowed, err := k.canI(attributes)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("Could not check permission to %s %s: %v", attributes.Verb, attributes.Resource, err))
+		} else if !allowed {
+			scope := "cluster-wide"
+			if attributes.Namespace != "" {
+				scope = "in namespace " + attributes.Namespace
+			}
+			errs = append(errs, fmt.Sprintf("Service account is not allowed to %s %s %s, check the RBAC rules",
+				attributes.Verb, attributes.Resource, scope))
+		}
+	}
+
+	return errs
+}
+
+// canI asks the API server whether the service account may perform the given action
+func (k *K8sClient) canI(attributes authorizationv1.ResourceAttributes) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+	}
+
+	result, err := k.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), review, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return result.Status.Allowed, nil
+}
+
+// watchNode calls callback when the public IP of the current node changes
+func (k *K8sClient) watchNode(callback func()) error {
+	nodeName, err := k.GetNodeName()
+	if err != nil {
+		return err
+	}
+
+	_, err = k.nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok := oldObj.(*v1.Node)
+			if !ok {
+				logger.Errorf("Error: oldObj is not a Node object")
+				return
+			}
+
+			newNode, ok := newObj.(*v1.Node)
+			if !ok {
+				logger.Errorf("Error: newObj is not a Node object")
+				return
+			}
+
+			// All nodes are cached in multi mode, only ours is of interest here
+			if newNode.Name != nodeName {
+				return
+			}
+
+			oldIP, _ := nodePublicIP(oldNode, k.publicIPLabel)
+			newIP, _ := nodePublicIP(newNode, k.publicIPLabel)
+			if oldIP != newIP {
+				logger.Infof("Node address change detected: %s -> %s", oldIP, newIP)
+				callback()
+			}
+		},
+	})
+	return err
+}
+
+// runLeaderElection takes part in the election for our own lease until ctx is
+// cancelled, calling callback whenever this instance gains or loses leadership
+func (k *K8sClient) runLeaderElection(ctx context.Context, callback func()) {
+	nodeName, err := k.GetNodeName()
+	if err != nil {
+		logger.Errorf("Error getting node name: %v", err)
+		return
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      k.leaseName,
+			Namespace: k.leaseNamespace,
+		},
+		Client: k.clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: nodeName,
+		},
+	}
+
+	electionConfig := leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				logger.Infof("Acquired lease %s/%s", k.leaseNamespace, k.leaseName)
+				k.isLeader.Store(true)
+				callback()
+			},
+			OnStoppedLeading: func() {
+				logger.Infof("Released lease %s/%s", k.leaseNamespace, k.leaseName)
+				k.isLeader.Store(false)
+				callback()
+			},
+			OnNewLeader: func(identity string) {
+				if identity != nodeName {
+					logger.Infof("Leader change detected: %s holds lease %s/%s", identity, k.leaseNamespace, k.leaseName)
+				}
+			},
+		},
+	}
+
+	// Run returns once leadership is lost, so keep taking part until shutdown
+	for ctx.Err() == nil {
+		elector, err := leaderelection.NewLeaderElector(electionConfig)
+		if err != nil {
+			logger.Errorf("Error creating leader elector: %v", err)
+			return
+		}
+		elector.Run(ctx)
+	}
 }
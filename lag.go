@@ -0,0 +1,40 @@
+package main
+
+import "time"
+
+// markTriggered remembers when an event asked for a reconcile. Only the oldest
+// event not yet covered by a reconcile counts, so debounced bursts report the
+// lag of their first event.
+func (s *Sentinel) markTriggered() {
+	s.triggerMu.Lock()
+	defer s.triggerMu.Unlock()
+	if s.triggeredAt.IsZero() {
+		s.triggeredAt = time.Now()
+	}
+}
+
+// takeTrigger returns when the reconcile starting now was triggered: the
+// oldest pending event, or start for reconciles run directly, e.g. by a timer
+func (s *Sentinel) takeTrigger(start time.Time) time.Time {
+	s.triggerMu.Lock()
+	defer s.triggerMu.Unlock()
+	triggered := s.triggeredAt
+	s.triggeredAt = time.Time{}
+	if triggered.IsZero() || triggered.After(start) {
+		return start
+	}
+	return triggered
+}
+
+// observeLag records how long after triggered a reconcile completed and warns
+// once that exceeds LagThreshold
+func (s *Sentinel) observeLag(triggered time.Time) {
+	lag := time.Since(triggered)
+	reconcileLagSeconds.Observe(lag.Seconds())
+	if s.Config.LagThreshold > 0 && lag > s.Config.LagThreshold {
+		logger.Warnf("Reconcile completed %s after it was triggered (threshold %s), check the provider latency, rate limit and retries",
+			lag.Round(time.Millisecond), s.Config.LagThreshold)
+		return
+	}
+	logger.Debugf("Reconcile completed %s after it was triggered", lag.Round(time.Millisecond))
+}
@@ -0,0 +1,54 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// errLockTimeout is returned if the lock file is still held by another process
+// when the timeout expires
+var errLockTimeout = errors.New("lock file is held by another process")
+
+// lockPollInterval is how often a held lock file is tried again
+const lockPollInterval = 100 * time.Millisecond
+
+// acquireFileLock takes an exclusive flock on path, so Sentinel processes on the
+// same host never update DNS at the same time. The returned function releases it.
+func acquireFileLock(ctx context.Context, path string, timeout time.Duration) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %v", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return func() {
+				_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+				file.Close()
+			}, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			file.Close()
+			return nil, fmt.Errorf("error locking %s: %v", path, err)
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, errLockTimeout
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errLockTimeout is returned if the lock file is still held by another process
+// when the timeout expires
+var errLockTimeout = errors.New("lock file is held by another process")
+
+// acquireFileLock is not supported without flock
+func acquireFileLock(context.Context, string, time.Duration) (func(), error) {
+	return nil, errors.New("SENTINEL_LOCK_FILE is only supported on Unix systems")
+}
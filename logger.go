@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// LogLevel is the severity of a log message
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// Logger writes leveled messages through the standard log package and drops
+// messages below the configured level
+type Logger struct {
+	level LogLevel
+}
+
+// logger is the application wide leveled logger
+var logger = &Logger{level: LevelInfo}
+
+// ParseLogLevel converts a level name (DEBUG, INFO, WARN, ERROR) into a LogLevel,
+// falling back to LevelInfo for unknown names
+func ParseLogLevel(name string) LogLevel {
+	switch strings.ToUpper(name) {
+	case "DEBUG":
+		return LevelDebug
+	case "WARN", "WARNING":
+		return LevelWarn
+	case "ERROR":
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// SetLevel changes the minimum level of messages that are written
+func (l *Logger) SetLevel(level LogLevel) {
+	l.level = level
+}
+
+// Debugf logs a message at debug level
+func (l *Logger) Debugf(format string, v ...any) {
+	l.logf(LevelDebug, "DEBUG", format, v...)
+}
+
+// Infof logs a message at info level
+func (l *Logger) Infof(format string, v ...any) {
+	l.logf(LevelInfo, "INFO", format, v...)
+}
+
+// Warnf logs a message at warning level
+func (l *Logger) Warnf(format string, v ...any) {
+	l.logf(LevelWarn, "WARN", format, v...)
+}
+
+// Errorf logs a message at error level
+func (l *Logger) Errorf(format string, v ...any) {
+	l.logf(LevelError, "ERROR", format, v...)
+}
+
+func (l *Logger) logf(level LogLevel, prefix, format string, v ...any) {
+	if level < l.level {
+		return
+	}
+	// Skip logf and the level method so Lshortfile reports the caller
+	_ = log.Output(3, "["+prefix+"] "+fmt.Sprintf(format, v...))
+}
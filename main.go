@@ -1,15 +1,25 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
-var version = "dev"
-
 func main() {
+	// Print build information for deployment scripts and exit
+	showVersion := flag.Bool("version", false, "print version and build information as JSON and exit")
+	flag.Parse()
+	if *showVersion || getEnvBool("PRINT_VERSION", false) {
+		printVersion()
+		return
+	}
+
 	// Set up logging
 	log.SetOutput(os.Stdout)
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
@@ -20,36 +30,125 @@ func main() {
 		log.Fatalf("Configuration error: %v", err)
 	}
 
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	// Configure log level
 	configureLogging(config.LogLevel)
 
+	// Route provider, IP echo and webhook requests through the configured proxy
+	configureProxy(config.HTTPSProxy)
+
+	// Export traces if an OTLP endpoint is configured
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		log.Fatalf("Error setting up tracing: %v", err)
+	}
+
 	// Create and initialize the sentinel
-	sentinel := NewSentinel(config)
+	sentinel, err := NewSentinel(config)
+	if err != nil {
+		log.Fatalf("Error initializing Sentinel: %v", err)
+	}
+
+	// Serve metrics if enabled
+	var metricsServer *http.Server
+	if config.MetricsAddr != "" {
+		metricsServer = startMetricsServer(config.MetricsAddr)
+	}
+
+	// Serve health checks if enabled
+	var healthServer *http.Server
+	if config.HealthAddr != "" {
+		healthServer = sentinel.startHealthServer(config.HealthAddr)
+	}
 
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// SIGHUP forces a rewrite of all records, e.g. after they were changed
+	// manually at the provider or its cache is stale
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			logger.Infof("Received SIGHUP, forcing DNS update")
+			_ = sentinel.ForceUpdateDNS(ctx)
+		}
+	}()
+
 	// Run the sentinel in a goroutine
+	var runErr error
+	done := make(chan struct{})
 	go func() {
-		log.Printf("Starting Sentinel DNS monitor (Version %s)", version)
-		sentinel.Run()
+		defer close(done)
+		logger.Infof("Starting Sentinel DNS monitor (Version %s)", version)
+		runErr = sentinel.Run(ctx)
 	}()
 
-	// Wait for termination signal
-	sig := <-sigChan
-	log.Printf("Received signal %v, shutting down...", sig)
+	// Wait for termination signal or the sentinel stopping on its own
+	select {
+	case sig := <-sigChan:
+		logger.Infof("Received signal %v, shutting down...", sig)
+		cancel()
+		<-done
+
+		// The reconcile context is cancelled by now, the withdrawal gets its own
+		if config.WithdrawOnShutdown {
+			withdrawCtx, cancelWithdraw := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := sentinel.WithdrawOnShutdown(withdrawCtx); err != nil {
+				logger.Errorf("Could not withdraw DNS records on shutdown: %v", err)
+			}
+			cancelWithdraw()
+		}
+	case <-done:
+		if config.Mode != ModeOneshot {
+			logger.Infof("Sentinel stopped unexpectedly")
+		}
+	}
+
+	shutdownServer(metricsServer, "metrics")
+	shutdownServer(healthServer, "health")
+
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logger.Errorf("Error flushing traces: %v", err)
+	}
+	cancelTracing()
+
+	if runErr != nil {
+		log.Fatalf("Sentinel failed: %v", runErr)
+	}
+}
+
+// shutdownServer gracefully stops an optional HTTP server
+func shutdownServer(server *http.Server, name string) {
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Errorf("Error shutting down %s server: %v", name, err)
+	}
 }
 
 // configureLogging sets up logging based on the configured level
 func configureLogging(level string) {
-	switch level {
-	case "DEBUG":
-		log.Println("Debug logging enabled")
+	logLevel := ParseLogLevel(level)
+	logger.SetLevel(logLevel)
+
+	switch logLevel {
+	case LevelDebug:
 		log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds | log.Lshortfile)
-	case "INFO":
-		log.SetFlags(log.Ldate | log.Ltime)
-	case "ERROR":
+		logger.Debugf("Debug logging enabled")
+	case LevelError:
 		log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	default:
 		log.SetFlags(log.Ldate | log.Ltime)
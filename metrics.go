@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	dnsUpdatesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sentinel_dns_updates_total",
+		Help: "Number of DNS record updates by result (attempted, succeeded, failed).",
+	}, []string{"result"})
+
+	leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sentinel_leader",
+		Help: "Whether this instance currently believes it is the leader (1) or not (0).",
+	})
+
+	lastSuccessfulUpdateGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sentinel_last_successful_update_timestamp_seconds",
+		Help: "Unix timestamp of the last successful DNS update.",
+	})
+
+	dnsAPIDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sentinel_dns_api_duration_seconds",
+		Help:    "Latency of DNS provider API calls by operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	dnsRateLimitWaitSeconds = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sentinel_dns_rate_limit_wait_seconds_total",
+		Help: "Total time DNS provider calls waited for the rate limit.",
+	})
+
+	dnsErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sentinel_dns_errors_total",
+		Help: "Number of failed DNS provider operations by provider and error category (auth, quota, rate_limit, invalid_record, not_found, server, other).",
+	}, []string{"provider", "category"})
+
+	reconcileLagSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sentinel_reconcile_lag_seconds",
+		Help:    "Time from an event or timer triggering a reconcile until it completed.",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	})
+
+	dnsPropagationTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sentinel_dns_propagation_total",
+		Help: "Number of propagation checks after DNS updates by result (confirmed, timeout).",
+	}, []string{"result"})
+)
+
+// observeDnsCall records the latency of a DNS provider API call started at start
+func observeDnsCall(operation string, start time.Time) {
+	dnsAPIDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// boolToFloat converts a boolean into a gauge value
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// startMetricsServer serves Prometheus metrics on addr in the background
+func startMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		logger.Infof("Serving metrics on %s/metrics", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Metrics server error: %v", err)
+		}
+	}()
+
+	return server
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// isNodeReady reports whether this node may publish its address in multi mode
+func (s *Sentinel) isNodeReady() (bool, error) {
+	checker, ok := s.orchestration.(NodeReadinessChecker)
+	if !ok {
+		return true, nil
+	}
+	return checker.IsNodeReady()
+}
+
+// addRecord makes sure desired is part of the record set without touching the
+// entries other nodes published (multi mode)
+func (s *Sentinel) addRecord(ctx context.Context, zone string, records []libdns.Record, desired libdns.Record) recordResult {
+	want := desired.RR()
+
+	for _, record := range records {
+		rr := record.RR()
+		if sameName(rr.Name, want.Name, zone) && rr.Type == want.Type && sameContent(rr.Type, rr.Data, want.Data) {
+			logger.Infof("DNS %s record %s contains %s", want.Type, want.Name, want.Data)
+			s.remember(zone, desired)
+			return recordUnchanged
+		}
+	}
+
+	event := Event{Event: EventDNSUpdated, Record: libdns.AbsoluteName(want.Name, zone), New: want.Data}
+	if s.Config.DryRun {
+		logger.Infof("Dry run: would add %s to %s record %s", want.Data, want.Type, want.Name)
+		event.Result = EventResultDryRun
+		s.emitEvent(event)
+		return recordDryRun
+	}
+
+	dnsUpdatesTotal.WithLabelValues("attempted").Inc()
+	err := s.withRetry(ctx, "add "+want.Type+" record "+want.Name, func(ctx context.Context) error {
+		defer observeDnsCall("create_records", time.Now())
+		defer s.invalidateRecords(zone)
+
+		_, err := s.dnsClient(zone).AppendRecords(ctx, zone, []libdns.Record{s.decorate(zone, desired)})
+		return err
+	})
+	if err != nil {
+		dnsUpdatesTotal.WithLabelValues("failed").Inc()
+		logger.Errorf("Adding %s to %s record failed: %v", want.Data, want.Type, s.reconcileError("add", zone, want.Name, err))
+		event.Result = EventResultFailed
+		s.emitEvent(event)
+		return recordFailed
+	}
+
+	s.remember(zone, desired)
+	dnsUpdatesTotal.WithLabelValues("succeeded").Inc()
+	lastSuccessfulUpdateGauge.SetToCurrentTime()
+	logger.Infof("Added %s to %s record %s", want.Data, want.Type, want.Name)
+	event.Result = EventResultSucceeded
+	s.emitEvent(event)
+
+	s.notifyWebhook(WebhookEvent{
+		Event:  WebhookEventDNSUpdated,
+		Record: libdns.AbsoluteName(want.Name, zone),
+		NewIP:  want.Data,
+	})
+	return recordUpdated
+}
+
+// remember adds record to the records published by this node in zone
+func (s *Sentinel) remember(zone string, record libdns.Record) {
+	if s.published == nil {
+		s.published = make(map[string][]libdns.Record)
+	}
+	if !containsRecord(s.published[zone], record) {
+		s.published[zone] = append(s.published[zone], record)
+	}
+}
+
+// withdrawStaleRecords removes records this node published earlier but no
+// longer wants, e.g. the old address after an IP change
+func (s *Sentinel) withdrawStaleRecords(ctx context.Context, zone string, desired []libdns.Record) error {
+	var stale, kept []libdns.Record
+	for _, published := range s.published[zone] {
+		if containsRecord(desired, published) {
+			kept = append(kept, published)
+		} else {
+			stale = append(stale, published)
+		}
+	}
+
+	if err := s.deleteRecords(ctx, zone, stale); err != nil {
+		return err
+	}
+	s.published[zone] = kept
+	return nil
+}
+
+// withdrawOwnRecords removes all records this node published, reason is logged
+// if there is anything to remove
+func (s *Sentinel) withdrawOwnRecords(ctx context.Context, reason string) error {
+	var errs []error
+	for zone, published := range s.published {
+		if len(published) == 0 {
+			continue
+		}
+
+		logger.Infof("%s, withdrawing its records in %s", reason, zone)
+		if err := s.deleteRecords(ctx, zone, published); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(s.published, zone)
+	}
+	return errors.Join(errs...)
+}
+
+// deleteRecords deletes records from zone, honoring dry run
+func (s *Sentinel) deleteRecords(ctx context.Context, zone string, records []libdns.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	for _, record := range records {
+		rr := record.RR()
+		if s.Config.DryRun {
+			logger.Infof("Dry run: would remove %s from %s record %s", rr.Data, rr.Type, rr.Name)
+		} else {
+			logger.Infof("Removing %s from %s record %s", rr.Data, rr.Type, rr.Name)
+		}
+	}
+	if s.Config.DryRun {
+		s.emitRemoved(zone, records, EventResultDryRun)
+		return nil
+	}
+
+	err := s.withRetry(ctx, "delete records", func(ctx context.Context) error {
+		defer observeDnsCall("delete_records", time.Now())
+		defer s.invalidateRecords(zone)
+		ctx, span := s.providerSpan(ctx, "DeleteRecords", zone, records[0].RR().Name)
+
+		_, err := s.dnsClient(zone).DeleteRecords(ctx, zone, records)
+		endSpan(span, err)
+		return err
+	})
+	if err != nil {
+		reconcileErr := s.reconcileError("delete", zone, records[0].RR().Name, err)
+		logger.Errorf("Could not remove records: %v", reconcileErr)
+		s.emitRemoved(zone, records, EventResultFailed)
+		return reconcileErr
+	}
+	s.emitRemoved(zone, records, EventResultSucceeded)
+	return nil
+}
+
+// emitRemoved writes a removal event with result for each of records
+func (s *Sentinel) emitRemoved(zone string, records []libdns.Record, result string) {
+	for _, record := range records {
+		rr := record.RR()
+		s.emitEvent(Event{Event: EventDNSRemoved, Record: libdns.AbsoluteName(rr.Name, zone), Old: rr.Data, Result: result})
+	}
+}
+
+// syncReadyNodes makes the record sets hold exactly the addresses of the ready
+// nodes, adding missing ones and pruning those of nodes that left or are not
+// ready anymore. Only the leader does this, so nodes do not fight over entries.
+func (s *Sentinel) syncReadyNodes(ctx context.Context, z ZoneRecords, records []libdns.Record) error {
+	zone := z.zone()
+
+	readyNodes, err := s.orchestration.ListReadyNodeIPs(ctx)
+	if err != nil {
+		logger.Errorf("Could not list ready nodes, skipping pruning: %v", err)
+		return err
+	}
+
+	// The addresses are published the way each node publishes its own
+	var ready []netip.Addr
+	for _, ips := range readyNodes {
+		for _, family := range []string{IPFamilyIPv4, IPFamilyIPv6} {
+			raw, ok := ips[family]
+			if !ok {
+				continue
+			}
+
+			ip, err := netip.ParseAddr(raw)
+			if err != nil {
+				logger.Warnf("Ignoring invalid node IP %q: %v", raw, err)
+				continue
+			}
+			ip, err = s.transformAddr(ip.Unmap())
+			if err != nil {
+				logger.Warnf("Ignoring node IP %s: %v", raw, err)
+				continue
+			}
+			if s.publishesFamily(ip) && (s.Config.AllowPrivateIP || isPublicIP(ip)) && !slices.Contains(ready, ip) {
+				ready = append(ready, ip)
+			}
+		}
+	}
+
+	var missing, stale []libdns.Record
+	for _, name := range z.recordNames() {
+		ttl := s.recordTTL(z, name)
+		present := make(map[netip.Addr]bool)
+		for _, record := range records {
+			rr := record.RR()
+			if (rr.Type != "A" && rr.Type != "AAAA") || !sameName(rr.Name, name, zone) {
+				continue
+			}
+
+			// Addresses of families this deployment does not publish are left alone
+			ip, err := netip.ParseAddr(strings.TrimSpace(rr.Data))
+			if err != nil || !s.publishesFamily(ip.Unmap()) {
+				continue
+			}
+			present[ip.Unmap()] = true
+
+			// Our own addresses were just reconciled and may not be listed yet
+			if !slices.Contains(ready, ip.Unmap()) && !containsRecord(s.published[zone], libdns.RR{Name: name, Type: rr.Type, Data: rr.Data}) {
+				stale = append(stale, record)
+			}
+		}
+
+		for _, ip := range ready {
+			if !present[ip] {
+				missing = append(missing, libdns.Address{Name: name, IP: ip, TTL: ttl})
+			}
+		}
+	}
+
+	for _, record := range missing {
+		rr := record.RR()
+		if s.Config.DryRun {
+			logger.Infof("Dry run: would add %s of ready node to %s record %s", rr.Data, rr.Type, rr.Name)
+		} else {
+			logger.Infof("Adding %s of ready node to %s record %s", rr.Data, rr.Type, rr.Name)
+		}
+	}
+	if len(missing) > 0 && !s.Config.DryRun {
+		err := s.withRetry(ctx, "add records of ready nodes", func(ctx context.Context) error {
+			defer observeDnsCall("create_records", time.Now())
+			defer s.invalidateRecords(zone)
+
+			_, err := s.dnsClient(zone).AppendRecords(ctx, zone, missing)
+			return err
+		})
+		if err != nil {
+			reconcileErr := s.reconcileError("add", zone, missing[0].RR().Name, err)
+			logger.Errorf("Could not add records of ready nodes: %v", reconcileErr)
+			return reconcileErr
+		}
+	}
+
+	if len(stale) > 0 {
+		logger.Infof("Pruning %d address(es) of nodes that are not ready anymore", len(stale))
+	}
+	return s.deleteRecords(ctx, zone, stale)
+}
+
+// containsRecord reports whether records holds a record with the same name, type and content as record
+func containsRecord(records []libdns.Record, record libdns.Record) bool {
+	want := record.RR()
+	for _, r := range records {
+		rr := r.RR()
+		if rr.Name == want.Name && rr.Type == want.Type && sameContent(rr.Type, rr.Data, want.Data) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+	"github.com/libdns/namecheap"
+)
+
+// Namecheap error numbers: 1011150 is returned for requests from an IP that
+// is not whitelisted, 2019166 and 2016166 for domains not in the account
+const (
+	namecheapErrInvalidIP      = "1011150"
+	namecheapErrDomainNotFound = "2019166"
+	namecheapErrNotInAccount   = "2016166"
+)
+
+// NamecheapDnsClient wraps the Namecheap provider to explain the errors of
+// the API, which the provider reports by message only
+type NamecheapDnsClient struct {
+	*namecheap.Provider
+}
+
+// GetRecords lists all records in zone
+func (c *NamecheapDnsClient) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	records, err := c.Provider.GetRecords(ctx, zone)
+	return records, c.explain(err)
+}
+
+// AppendRecords adds records to zone
+func (c *NamecheapDnsClient) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.AppendRecords(ctx, zone, records)
+	return records, c.explain(err)
+}
+
+// SetRecords replaces the records of zone sharing name and type with records
+func (c *NamecheapDnsClient) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.SetRecords(ctx, zone, records)
+	return records, c.explain(err)
+}
+
+// DeleteRecords removes records from zone. The provider only deletes hosts
+// with a matching TTL, so records are matched against the current hosts first.
+func (c *NamecheapDnsClient) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	current, err := c.Provider.GetRecords(ctx, zone)
+	if err != nil {
+		return nil, c.explain(err)
+	}
+
+	var existing []libdns.Record
+	for _, record := range records {
+		rr := record.RR()
+		for _, host := range current {
+			stored := host.RR()
+			if sameName(stored.Name, rr.Name, zone) && stored.Type == rr.Type && sameContent(rr.Type, stored.Data, rr.Data) {
+				existing = append(existing, host)
+				break
+			}
+		}
+	}
+	if len(existing) == 0 {
+		return nil, nil
+	}
+
+	deleted, err := c.Provider.DeleteRecords(ctx, zone, existing)
+	return deleted, c.explain(err)
+}
+
+// explain adds the error number to the errors of requests from IPs that are
+// not whitelisted and of domains missing from the account
+func (c *NamecheapDnsClient) explain(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "request ip"):
+		return fmt.Errorf("(%s) %w (Namecheap only accepts API requests from whitelisted IPs: whitelist %s under Profile > Tools > API Access and make sure Sentinel reaches the API from it, or correct SENTINEL_NAMECHEAP_CLIENT_IP)", namecheapErrInvalidIP, err, c.ClientIP)
+	case strings.Contains(message, "domain not found"):
+		return fmt.Errorf("%w: (%s) %w", ErrZoneNotFound, namecheapErrDomainNotFound, err)
+	case strings.Contains(message, "not associated with your account"):
+		return fmt.Errorf("%w: (%s) %w", ErrZoneNotFound, namecheapErrNotInAccount, err)
+	case strings.Contains(message, "invalid zone"):
+		return fmt.Errorf("%w: %w", ErrZoneNotFound, err)
+	}
+	return err
+}
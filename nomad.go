@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// nomadLeaderPollInterval is how often the leader is polled, as Nomad does not
+// publish leadership changes on its event stream
+const nomadLeaderPollInterval = 10 * time.Second
+
+// nomadRequestTimeout bounds every Nomad API request
+const nomadRequestTimeout = 30 * time.Second
+
+// NomadClient handles communication with the Nomad HTTP API
+type NomadClient struct {
+	client  *http.Client
+	address string
+	token   string
+
+	// publicIPLabel is the node metadata key holding the public IP
+	publicIPLabel string
+}
+
+// NomadAgentSelf represents the parts of the Nomad /v1/agent/self response we use
+type NomadAgentSelf struct {
+	Config struct {
+		Server struct {
+			Enabled bool `json:"Enabled"`
+		} `json:"Server"`
+		Client struct {
+			Enabled bool `json:"Enabled"`
+		} `json:"Client"`
+	} `json:"config"`
+	Member struct {
+		Name string            `json:"Name"`
+		Addr string            `json:"Addr"`
+		Tags map[string]string `json:"Tags"`
+	} `json:"member"`
+	Stats struct {
+		Client struct {
+			NodeID string `json:"node_id"`
+		} `json:"client"`
+	} `json:"stats"`
+}
+
+// NomadNode represents Nomad client node information
+type NomadNode struct {
+	ID     string            `json:"ID"`
+	Name   string            `json:"Name"`
+	Status string            `json:"Status"`
+	Meta   map[string]string `json:"Meta"`
+}
+
+// NewNomadClient creates a new Nomad API client using the standard NOMAD_ADDR
+// and NOMAD_TOKEN environment variables
+func NewNomadClient(config *Config) *NomadClient {
+	address := os.Getenv("NOMAD_ADDR")
+	if address == "" {
+		address = "http://127.0.0.1:4646"
+	}
+
+	return &NomadClient{
+		client:  &http.Client{Timeout: nomadRequestTimeout},
+		address: strings.TrimSuffix(address, "/"),
+		token:   os.Getenv("NOMAD_TOKEN"),
+
+		publicIPLabel: config.PublicIPLabel,
+	}
+}
+
+// get performs a GET request against the Nomad API and decodes the JSON response
+// into v, the request is cancelled with ctx
+func (n *NomadClient) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", n.address+path, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	if n.token != "" {
+		req.Header.Set("X-Nomad-Token", n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error connecting to Nomad API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from Nomad API %s: %s", path, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error parsing response of %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// getAgentSelf retrieves information about the local Nomad agent
+func (n *NomadClient) getAgentSelf(ctx context.Context) (*NomadAgentSelf, error) {
+	var self NomadAgentSelf
+	if err := n.get(ctx, "/v1/agent/self", &self); err != nil {
+		return nil, err
+	}
+	return &self, nil
+}
+
+// getLeader retrieves the RPC address of the current cluster leader
+func (n *NomadClient) getLeader(ctx context.Context) (string, error) {
+	var leader string
+	if err := n.get(ctx, "/v1/status/leader", &leader); err != nil {
+		return "", err
+	}
+	return leader, nil
+}
+
+func (n *NomadClient) GetConfigurationErrors() []string {
+	var errs []string
+
+	self, err := n.getAgentSelf(context.Background())
+	if err != nil {
+		return append(errs, fmt.Sprintf("Nomad agent not reachable at %s: %v", n.address, err))
+	}
+
+	if !self.Config.Server.Enabled {
+		errs = append(errs, "Nomad agent is not running in server mode")
+	}
+	if !self.Config.Client.Enabled {
+		errs = append(errs, "Nomad agent is not running in client mode, node metadata is unavailable")
+	}
+
+	return errs
+}
+
+// GetNodeName retrieves the name of the local Nomad agent
+func (n *NomadClient) GetNodeName() (string, error) {
+	self, err := n.getAgentSelf(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	if self.Member.Name == "" {
+		return "", fmt.Errorf("could not determine Nomad agent name")
+	}
+
+	return self.Member.Name, nil
+}
+
+// getCurrentNode retrieves the client node of the local Nomad agent
+func (n *NomadClient) getCurrentNode(ctx context.Context) (*NomadNode, error) {
+	self, err := n.getAgentSelf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeID := self.Stats.Client.NodeID
+	if nodeID == "" {
+		return nil, fmt.Errorf("could not determine Nomad node ID")
+	}
+
+	var node NomadNode
+	if err := n.get(ctx, "/v1/node/"+nodeID, &node); err != nil {
+		return nil, fmt.Errorf("error getting node: %v", err)
+	}
+
+	return &node, nil
+}
+
+// GetCurrentNodeLabel retrieves a specific metadata entry of the current node
+func (n *NomadClient) GetCurrentNodeLabel(name string) (string, error) {
+	node, err := n.getCurrentNode(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	value, exists := node.Meta[name]
+	if !exists {
+		return "", fmt.Errorf("meta %s not found on node %s", name, node.Name)
+	}
+
+	return value, nil
+}
+
+// IsNodeReady checks whether the current client node is in the ready state
+func (n *NomadClient) IsNodeReady() (bool, error) {
+	node, err := n.getCurrentNode(context.Background())
+	if err != nil {
+		return false, err
+	}
+
+	return node.Status == "ready", nil
+}
+
+// GetNodePublicIP retrieves the public IP address from the node's metadata
+func (n *NomadClient) GetNodePublicIP() (string, error) {
+	return n.GetCurrentNodeLabel(n.publicIPLabel)
+}
+
+// ListReadyNodeIPs returns the public IP metadata of all ready client nodes per
+// IP family, nodes without it are skipped
+func (n *NomadClient) ListReadyNodeIPs(ctx context.Context) ([]map[string]string, error) {
+	var stubs []NomadNode
+	if err := n.get(ctx, "/v1/nodes", &stubs); err != nil {
+		return nil, fmt.Errorf("error listing nodes: %v", err)
+	}
+
+	var nodeIPs []map[string]string
+	for _, stub := range stubs {
+		if stub.Status != "ready" {
+			continue
+		}
+
+		// The node list does not include metadata, so every node is fetched
+		var node NomadNode
+		if err := n.get(ctx, "/v1/node/"+stub.ID, &node); err != nil {
+			return nil, fmt.Errorf("error getting node %s: %v", stub.Name, err)
+		}
+
+		ips := make(map[string]string)
+		addIPByFamily(ips, node.Meta[n.publicIPLabel])
+		if len(ips) == 0 {
+			logger.Warnf("Node %s has no %s meta, skipping it", node.Name, n.publicIPLabel)
+			continue
+		}
+		nodeIPs = append(nodeIPs, ips)
+	}
+
+	return nodeIPs, nil
+}
+
+// IsLeader checks if the local agent is the leader of the Nomad server cluster.
+// An error means leadership could not be determined.
+func (n *NomadClient) IsLeader(ctx context.Context) (bool, error) {
+	self, err := n.getAgentSelf(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error getting Nomad agent info: %v", err)
+	}
+
+	leader, err := n.getLeader(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error getting Nomad leader: %v", err)
+	}
+
+	// The leader is reported by its RPC address, which is the member address
+	// combined with the RPC port tag
+	return leader == net.JoinHostPort(self.Member.Addr, self.Member.Tags["port"]), nil
+}
+
+// WatchEvents polls the Nomad leader and calls callback when it changes
+func (n *NomadClient) WatchEvents(ctx context.Context, callback func()) {
+	lastLeader, err := n.getLeader(ctx)
+	if err != nil {
+		logger.Errorf("Error getting Nomad leader: %v", err)
+	}
+
+	ticker := time.NewTicker(nomadLeaderPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		leader, err := n.getLeader(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Errorf("Error getting Nomad leader: %v", err)
+			continue
+		}
+
+		if leader != lastLeader {
+			logger.Infof("Leader change detected: %s -> %s", lastLeader, leader)
+			lastLeader = leader
+			callback()
+		}
+	}
+}
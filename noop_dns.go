@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/libdns/libdns"
+)
+
+// NoopDnsClient is an in-memory DnsClient that never talks to a real provider.
+// GetRecords returns whatever was written before, which makes it useful for
+// demos, staging and integration tests.
+type NoopDnsClient struct {
+	mu    sync.Mutex
+	zones map[string][]libdns.Record
+}
+
+// NewNoopDnsClient creates an empty in-memory DNS client
+func NewNoopDnsClient() *NoopDnsClient {
+	return &NoopDnsClient{zones: make(map[string][]libdns.Record)}
+}
+
+// GetRecords returns all records stored for zone
+func (n *NoopDnsClient) GetRecords(_ context.Context, zone string) ([]libdns.Record, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]libdns.Record(nil), n.zones[zone]...), nil
+}
+
+// AppendRecords adds records to zone
+func (n *NoopDnsClient) AppendRecords(_ context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.zones[zone] = append(n.zones[zone], records...)
+	logger.Infof("Noop DNS provider: appended %d record(s) to %s", len(records), zone)
+	return records, nil
+}
+
+// SetRecords replaces all records of zone sharing name and type with one of records
+func (n *NoopDnsClient) SetRecords(_ context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var kept []libdns.Record
+	for _, existing := range n.zones[zone] {
+		if !n.replaces(records, existing, zone) {
+			kept = append(kept, existing)
+		}
+	}
+
+	n.zones[zone] = append(kept, records...)
+	logger.Infof("Noop DNS provider: set %d record(s) in %s", len(records), zone)
+	return records, nil
+}
+
+// DeleteRecords removes records from zone matching name, type and, if set, content
+func (n *NoopDnsClient) DeleteRecords(_ context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var kept, deleted []libdns.Record
+	for _, existing := range n.zones[zone] {
+		if n.matches(records, existing, zone) {
+			deleted = append(deleted, existing)
+		} else {
+			kept = append(kept, existing)
+		}
+	}
+
+	n.zones[zone] = kept
+	logger.Infof("Noop DNS provider: deleted %d record(s) from %s", len(deleted), zone)
+	return deleted, nil
+}
+
+// matches reports whether existing is selected by any of records for deletion
+func (n *NoopDnsClient) matches(records []libdns.Record, existing libdns.Record, zone string) bool {
+	old := existing.RR()
+	for _, record := range records {
+		rr := record.RR()
+		if rr.Type == old.Type && sameName(rr.Name, old.Name, zone) && (rr.Data == "" || sameContent(rr.Type, rr.Data, old.Data)) {
+			return true
+		}
+	}
+	return false
+}
+
+// replaces reports whether any of records has the same name and type as existing
+func (n *NoopDnsClient) replaces(records []libdns.Record, existing libdns.Record, zone string) bool {
+	old := existing.RR()
+	for _, record := range records {
+		rr := record.RR()
+		if rr.Type == old.Type && sameName(rr.Name, old.Name, zone) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,62 @@
 package main
 
+import (
+	"context"
+	"net/netip"
+)
+
+// IP families accepted for SENTINEL_IP_FAMILY and reported by NodeAddressReader
+const (
+	IPFamilyIPv4 = "ipv4"
+	IPFamilyIPv6 = "ipv6"
+	IPFamilyDual = "dual" // publish both families, each when available
+)
+
 // OrchestrationAdapter defines the interface for orchestration-specific operations
 type OrchestrationAdapter interface {
 	GetConfigurationErrors() []string
 	GetNodeName() (string, error)
 	GetNodePublicIP() (string, error)
-	IsLeader() bool
-	WatchEvents(callback func())
+	// IsLeader reports whether this node is the leader, or an error if that
+	// could not be determined (e.g. the orchestrator is unreachable)
+	IsLeader(ctx context.Context) (bool, error)
+	// ListReadyNodeIPs returns the public addresses of each ready node of the
+	// cluster per IP family (IPFamilyIPv4, IPFamilyIPv6)
+	ListReadyNodeIPs(ctx context.Context) ([]map[string]string, error)
+	WatchEvents(ctx context.Context, callback func())
+}
+
+// NodeReadinessChecker is implemented by adapters that can tell whether the node
+// Sentinel runs on is ready to receive traffic. Adapters without it are assumed ready.
+type NodeReadinessChecker interface {
+	IsNodeReady() (bool, error)
+}
+
+// NodeAddressReader is implemented by adapters that can report the public
+// addresses of the node Sentinel runs on per IP family (IPFamilyIPv4, IPFamilyIPv6)
+type NodeAddressReader interface {
+	GetNodePublicIPs() (map[string]string, error)
+}
+
+// addIPByFamily adds raw to ips under its IP family, unless that family already
+// has an address or raw is no IP
+func addIPByFamily(ips map[string]string, raw string) {
+	ip, err := netip.ParseAddr(raw)
+	if err != nil {
+		return
+	}
+
+	family := IPFamilyIPv4
+	if ip = ip.Unmap(); ip.Is6() {
+		family = IPFamilyIPv6
+	}
+	if _, exists := ips[family]; !exists {
+		ips[family] = ip.String()
+	}
+}
+
+// NodeLabelReader is implemented by adapters that can read labels (or metadata)
+// of the node Sentinel runs on
+type NodeLabelReader interface {
+	GetCurrentNodeLabel(name string) (string, error)
 }
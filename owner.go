@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// ownerRecordPrefix is prepended to a record name to form the name of its
+// ownership TXT record. A TXT record next to the managed record itself would
+// conflict with CNAME records.
+const ownerRecordPrefix = "_sentinel-owner"
+
+// ownerRecordName returns the name of the ownership TXT record of name
+func ownerRecordName(name string) string {
+	if name == "" || name == "@" {
+		return ownerRecordPrefix
+	}
+	return ownerRecordPrefix + "." + name
+}
+
+// ownerText returns the content of the ownership TXT record of this deployment
+func (s *Sentinel) ownerText() string {
+	return "heritage=sentinel,sentinel/owner=" + s.Config.OwnerID
+}
+
+// claimOwnership reports whether name may be managed by this deployment. An
+// unowned name is claimed by creating its ownership TXT record, a name whose
+// ownership record holds anything else belongs to another deployment or tool
+// and is left alone.
+func (s *Sentinel) claimOwnership(ctx context.Context, zone string, records []libdns.Record, name string) bool {
+	ownerName := ownerRecordName(name)
+	want := s.ownerText()
+
+	var foreign []string
+	for _, record := range records {
+		rr := record.RR()
+		if rr.Type != "TXT" || !sameName(rr.Name, ownerName, zone) {
+			continue
+		}
+
+		text := strings.Trim(rr.Data, `"`)
+		if text == want {
+			return true
+		}
+		foreign = append(foreign, text)
+	}
+
+	if len(foreign) > 0 {
+		if strings.HasPrefix(foreign[0], "heritage=sentinel,") {
+			logger.Errorf("Record %s is owned by another Sentinel deployment (%s), refusing to update it", name, foreign[0])
+		} else {
+			logger.Errorf("Record %s is owned by another tool (TXT record %s holds %q), refusing to update it", name, ownerName, foreign[0])
+		}
+		return false
+	}
+
+	if s.Config.DryRun {
+		logger.Infof("Dry run: would claim record %s with TXT record %s", name, ownerName)
+		return true
+	}
+
+	logger.Infof("Claiming record %s with TXT record %s", name, ownerName)
+	err := s.withRetry(ctx, "create TXT record "+ownerName, func(ctx context.Context) error {
+		defer observeDnsCall("create_records", time.Now())
+		defer s.invalidateRecords(zone)
+
+		_, err := s.dnsClient(zone).AppendRecords(ctx, zone, []libdns.Record{libdns.TXT{
+			Name: ownerName,
+			TTL:  time.Duration(s.Config.RecordTTL) * time.Second,
+			Text: want,
+		}})
+		return err
+	})
+	if err != nil {
+		logger.Errorf("Could not claim record %s: %v", name, s.reconcileError("create", zone, ownerName, err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+	"github.com/libdns/powerdns"
+	"github.com/mittwald/go-powerdns/pdnshttp"
+)
+
+// PowerDnsClient wraps the PowerDNS provider to report zones missing on the
+// server as ErrZoneNotFound
+type PowerDnsClient struct {
+	*powerdns.Provider
+}
+
+// GetRecords lists all records in zone
+func (c *PowerDnsClient) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	records, err := c.Provider.GetRecords(ctx, zone)
+	return records, c.explain(zone, err)
+}
+
+// AppendRecords adds records to zone
+func (c *PowerDnsClient) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.AppendRecords(ctx, zone, records)
+	return records, c.explain(zone, err)
+}
+
+// SetRecords replaces the records of zone sharing name and type with records
+func (c *PowerDnsClient) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.SetRecords(ctx, zone, records)
+	return records, c.explain(zone, err)
+}
+
+// DeleteRecords removes records from zone
+func (c *PowerDnsClient) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	records, err := c.Provider.DeleteRecords(ctx, zone, records)
+	return records, c.explain(zone, err)
+}
+
+// explain wraps the errors the provider returns for zones the server does not serve
+func (c *PowerDnsClient) explain(zone string, err error) error {
+	if err != nil && (err.Error() == "zone not found" || pdnshttp.IsNotFound(err)) {
+		return fmt.Errorf("zone %s not found on server %s: %w", strings.TrimSuffix(zone, "."), c.ServerID, ErrZoneNotFound)
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+)
+
+// Categories of DNS provider errors, telling users what to act on
+const (
+	ProviderErrorAuth          = "auth"
+	ProviderErrorQuota         = "quota"
+	ProviderErrorRateLimit     = "rate_limit"
+	ProviderErrorInvalidRecord = "invalid_record"
+	ProviderErrorNotFound      = "not_found"
+	ProviderErrorServer        = "server"
+)
+
+// Shapes the providers report their result codes in
+var (
+	// INWX "(2303) Object does not exist", Namecheap "(1011150) Invalid request IP",
+	// Bunny "Forbidden (403)"
+	providerCodePattern = regexp.MustCompile(`\((\d{3,7})\)`)
+
+	// Vultr "unexpected status ...: 403 Forbidden", Cloud DNS "googleapi: Error
+	// 403: Forbidden", PowerDNS and deSEC "unexpected status code 403: ..."
+	httpStatusPattern = regexp.MustCompile(`\b([45]\d\d) [A-Z][a-z]|googleapi: Error ([45]\d\d)|status code ([45]\d\d)`)
+)
+
+// inwxCodeCategories maps INWX result codes to categories
+var inwxCodeCategories = map[string]string{
+	"2003": ProviderErrorInvalidRecord, // required parameter missing
+	"2004": ProviderErrorInvalidRecord, // parameter value range error
+	"2005": ProviderErrorInvalidRecord, // parameter value syntax error
+	"2104": ProviderErrorQuota,         // billing failure
+	"2200": ProviderErrorAuth,          // authentication error
+	"2201": ProviderErrorAuth,          // authorization failed
+	"2303": ProviderErrorNotFound,      // object does not exist
+	"2306": ProviderErrorInvalidRecord, // parameter value policy error
+	"2308": ProviderErrorQuota,         // data management policy violation, e.g. record limits
+	"2400": ProviderErrorServer,        // command failed
+	"2502": ProviderErrorRateLimit,     // session limit exceeded
+}
+
+// namecheapCodeCategories maps Namecheap error numbers to categories
+var namecheapCodeCategories = map[string]string{
+	"1011102":                  ProviderErrorAuth, // API key invalid
+	namecheapErrInvalidIP:      ProviderErrorAuth,
+	namecheapErrDomainNotFound: ProviderErrorNotFound,
+	namecheapErrNotInAccount:   ProviderErrorNotFound,
+}
+
+// providerErrorCode extracts the result code the provider reported in err and
+// its category. Either is empty if the error does not reveal it.
+func providerErrorCode(provider string, err error) (code, category string) {
+	if match := providerCodePattern.FindStringSubmatch(err.Error()); match != nil {
+		code = match[1]
+	} else if match := httpStatusPattern.FindStringSubmatch(err.Error()); match != nil {
+		code = match[1] + match[2] + match[3]
+	}
+
+	switch {
+	case provider == DnsProviderInwx && len(code) == 4:
+		category = inwxCodeCategories[code]
+	case provider == DnsProviderNamecheap && len(code) == 7:
+		category = namecheapCodeCategories[code]
+	case len(code) == 3:
+		category = httpStatusCategory(code)
+	}
+
+	if category == "" && errors.Is(err, ErrZoneNotFound) {
+		category = ProviderErrorNotFound
+	}
+	return code, category
+}
+
+// httpStatusCategory maps HTTP status codes of REST APIs to categories
+func httpStatusCategory(status string) string {
+	switch status {
+	case "401", "403":
+		return ProviderErrorAuth
+	case "402":
+		return ProviderErrorQuota
+	case "404":
+		return ProviderErrorNotFound
+	case "400", "409", "422":
+		return ProviderErrorInvalidRecord
+	case "429":
+		return ProviderErrorRateLimit
+	}
+	if status[0] == '5' {
+		return ProviderErrorServer
+	}
+	return ""
+}
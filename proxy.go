@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// httpTransport is shared by all outbound HTTP clients: DNS provider APIs, the
+// IP echo service and webhooks. The libdns providers create their clients
+// without a transport, so it has to be http.DefaultTransport itself.
+var httpTransport = http.DefaultTransport.(*http.Transport)
+
+// configureProxy routes outbound HTTP through the proxies of the standard
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables. A non-empty httpsProxy
+// replaces HTTPS_PROXY.
+func configureProxy(httpsProxy string) {
+	proxyConfig := httpproxy.FromEnvironment()
+	if httpsProxy != "" {
+		proxyConfig.HTTPSProxy = httpsProxy
+		logger.Infof("Using HTTPS proxy %s", redactProxyURL(httpsProxy))
+	}
+
+	proxyFunc := proxyConfig.ProxyFunc()
+	httpTransport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// redactProxyURL hides the password of proxy URLs with credentials for logging
+func redactProxyURL(proxyURL string) string {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return proxyURL
+	}
+	return u.Redacted()
+}
+
+// validateProxyURL checks that proxyURL is an absolute URL with a host
+func validateProxyURL(proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("HTTPS proxy %q is not a valid URL", redactProxyURL(proxyURL))
+	}
+	return nil
+}
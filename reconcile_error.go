@@ -0,0 +1,60 @@
+package main
+
+import "fmt"
+
+// ReconcileError is a failed DNS provider operation together with the context
+// needed to debug it when several providers, zones or records are involved.
+// Code and Category hold the result code the provider reported, if any, and
+// what kind of failure it stands for, e.g. ProviderErrorAuth.
+type ReconcileError struct {
+	Provider  string
+	Zone      string
+	Record    string
+	Operation string
+	Code      string
+	Category  string
+	Err       error
+}
+
+func (e *ReconcileError) Error() string {
+	msg := fmt.Sprintf("%s in zone %s via %s failed: %v", e.Operation, e.Zone, e.Provider, e.Err)
+	if e.Record != "" {
+		msg = fmt.Sprintf("%s of %s in zone %s via %s failed: %v", e.Operation, e.Record, e.Zone, e.Provider, e.Err)
+	}
+
+	switch {
+	case e.Code != "" && e.Category != "":
+		return fmt.Sprintf("%s [provider code %s, %s]", msg, e.Code, e.Category)
+	case e.Code != "":
+		return fmt.Sprintf("%s [provider code %s]", msg, e.Code)
+	case e.Category != "":
+		return fmt.Sprintf("%s [%s]", msg, e.Category)
+	}
+	return msg
+}
+
+func (e *ReconcileError) Unwrap() error {
+	return e.Err
+}
+
+// reconcileError wraps err of a DNS operation with the provider, zone and record
+// involved and the result code the provider reported
+func (s *Sentinel) reconcileError(operation, zone, record string, err error) *ReconcileError {
+	provider := s.providerName(zone)
+	code, category := providerErrorCode(provider, err)
+	if category != "" {
+		dnsErrorsTotal.WithLabelValues(provider, category).Inc()
+	} else {
+		dnsErrorsTotal.WithLabelValues(provider, "other").Inc()
+	}
+
+	return &ReconcileError{
+		Provider:  provider,
+		Zone:      zone,
+		Record:    record,
+		Operation: operation,
+		Code:      code,
+		Category:  category,
+		Err:       err,
+	}
+}
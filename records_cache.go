@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// recordsCache keeps the last GetRecords result of each zone for a short time,
+// so reconciles without changes do not count against the provider's API quota
+type recordsCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedRecords
+}
+
+// cachedRecords are the records of a zone as read at fetched
+type cachedRecords struct {
+	records []libdns.Record
+	fetched time.Time
+}
+
+// getRecords returns the records of zone, from the cache if they were read less
+// than RecordsCacheTTL ago unless refresh is set
+func (s *Sentinel) getRecords(ctx context.Context, zone string, refresh bool) ([]libdns.Record, error) {
+	if s.Config.RecordsCacheTTL > 0 && !refresh {
+		s.recordsCache.mu.Lock()
+		entry, ok := s.recordsCache.entries[zone]
+		s.recordsCache.mu.Unlock()
+
+		if ok && time.Since(entry.fetched) < s.Config.RecordsCacheTTL {
+			logger.Debugf("Using records of %s cached %s ago", zone, time.Since(entry.fetched).Round(time.Second))
+			return entry.records, nil
+		}
+	}
+
+	var records []libdns.Record
+	err := s.withRetry(ctx, "get records", func(ctx context.Context) error {
+		defer observeDnsCall("get_records", time.Now())
+		ctx, span := s.providerSpan(ctx, "GetRecords", zone, "")
+
+		var err error
+		records, err = s.dnsClient(zone).GetRecords(ctx, zone)
+		endSpan(span, err)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Config.RecordsCacheTTL > 0 {
+		s.recordsCache.mu.Lock()
+		if s.recordsCache.entries == nil {
+			s.recordsCache.entries = make(map[string]cachedRecords)
+		}
+		s.recordsCache.entries[zone] = cachedRecords{records: records, fetched: time.Now()}
+		s.recordsCache.mu.Unlock()
+	}
+
+	return records, nil
+}
+
+// invalidateRecords drops the cached records of zone after it was written to,
+// whether the write succeeded or not
+func (s *Sentinel) invalidateRecords(zone string) {
+	s.recordsCache.mu.Lock()
+	defer s.recordsCache.mu.Unlock()
+	delete(s.recordsCache.entries, zone)
+}
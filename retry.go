@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"time"
+)
+
+// Error shapes of the DNS providers that indicate a transient failure, see transientError
+var (
+	// Bunny reports "Internal Server Error (500)", Cloud DNS "googleapi: Error 503: ..."
+	// and PowerDNS and deSEC "unexpected status code 503: ...". govultr retries
+	// these itself before giving up.
+	transientStatusPattern = regexp.MustCompile(`\((429|5\d\d)\)|\b(429|5\d\d) [A-Z][a-z]|googleapi: Error (429|5\d\d)|status code (429|5\d\d)`)
+
+	// INWX result codes 2400 (command failed) and 25xx (server closing connection,
+	// session limit exceeded), reported as "(2400) Command failed"
+	transientInwxCodePattern = regexp.MustCompile(`\((2400|25\d\d)\)`)
+
+	// Expired sessions or tokens are renewed by the next login
+	expiredPattern = regexp.MustCompile(`(?i)\b(session|token)\b.*\bexpired\b`)
+)
+
+// withRetry calls fn until it succeeds or the configured number of retries is
+// exhausted, doubling the delay between attempts starting at DnsRetryBase.
+// Every attempt gets its own context limited to DnsTimeout, so a hung provider
+// API call cannot block the reconcile.
+func (s *Sentinel) withRetry(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	attempts := s.Config.DnsMaxRetries + 1
+	delay := s.Config.DnsRetryBase
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = s.attempt(ctx, fn)
+		if err == nil {
+			return nil
+		}
+
+		// Retrying invalid credentials or a missing zone only delays the error
+		if !transientError(err) {
+			s.dnsFailures++
+			logger.Errorf("DNS %s failed permanently, not retrying (%d failures in total): %v", op, s.dnsFailures, err)
+			return fmt.Errorf("%s failed: %w", op, err)
+		}
+
+		logger.Warnf("DNS %s failed (attempt %d/%d): %v", op, attempt, attempts, err)
+		if attempt == attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	s.dnsFailures++
+	logger.Errorf("DNS %s failed after %d attempts (%d failures in total)", op, attempts, s.dnsFailures)
+
+	return fmt.Errorf("%s failed after %d attempts: %w", op, attempts, err)
+}
+
+// attempt calls fn once with a context limited to DnsTimeout, if set
+func (s *Sentinel) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := s.waitRateLimit(ctx); err != nil {
+		return err
+	}
+
+	if s.Config.DnsTimeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Config.DnsTimeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// waitRateLimit blocks until the DNS rate limit allows another provider call,
+// so reconciles during churn are slowed down instead of failing
+func (s *Sentinel) waitRateLimit(ctx context.Context) error {
+	if s.dnsLimiter == nil {
+		return nil
+	}
+
+	reservation := s.dnsLimiter.Reserve()
+	delay := reservation.Delay()
+	if delay <= 0 {
+		return nil
+	}
+
+	logger.Infof("DNS rate limit of %d calls per minute reached, waiting %s", s.Config.DnsRateLimit, delay.Round(time.Millisecond))
+	dnsRateLimitWaitSeconds.Add(delay.Seconds())
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// transientError reports whether err is a transient DNS provider failure worth
+// retrying. Treated as transient are:
+//   - network errors (connection refused or reset, DNS lookups, timeouts) and unexpected EOFs
+//   - attempts cut off by DnsTimeout
+//   - responses that are no valid JSON, usually error pages of a proxy
+//   - HTTP 429 and 5xx statuses
+//   - INWX result codes 2400 and 25xx
+//   - expired sessions or tokens
+//
+// Everything else is permanent, e.g. invalid credentials (HTTP 401/403, INWX
+// 2200), a nonexistent zone (INWX 2303, "zone not found") or invalid records.
+func transientError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return true
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return true
+	}
+
+	msg := err.Error()
+	return transientStatusPattern.MatchString(msg) || transientInwxCodePattern.MatchString(msg) || expiredPattern.MatchString(msg)
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	r53 "github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/libdns/libdns"
+	"github.com/libdns/route53"
+)
+
+// Route53DnsClient manages records in Amazon Route 53. Plain records go
+// through libdns/route53, which knows no routing policies, so the record sets
+// of SENTINEL_RECORD_SET_ID are read and written through the AWS SDK.
+type Route53DnsClient struct {
+	*route53.Provider
+	options RecordOptions
+
+	once    sync.Once
+	client  *r53.Client
+	initErr error
+
+	mu      sync.Mutex
+	zoneIDs map[string]string
+}
+
+// route53RoutedRecord is a record of the record set described by options
+type route53RoutedRecord struct {
+	libdns.Record
+	options RecordOptions
+}
+
+// DecorateRecord marks record as part of the routed record set of options
+func (c *Route53DnsClient) DecorateRecord(record libdns.Record, options RecordOptions) libdns.Record {
+	return route53RoutedRecord{Record: record, options: options}
+}
+
+// GetRecords lists all records in zone. Names with routed record sets only
+// list the values of our set, the sets of other nodes are not ours to change.
+func (c *Route53DnsClient) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	records, err := c.Provider.GetRecords(ctx, zone)
+	if err != nil || c.options.IsZero() {
+		return records, c.explain(zone, err)
+	}
+
+	sets, err := c.routedSets(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	return route53MergeRouted(zone, records, sets, c.options.SetIdentifier), nil
+}
+
+// AppendRecords adds records to zone
+func (c *Route53DnsClient) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	routed, plain := splitRoute53Records(records)
+	if len(plain) > 0 {
+		added, err := c.Provider.AppendRecords(ctx, zone, plain)
+		if err != nil {
+			return added, c.explain(zone, err)
+		}
+	}
+	if len(routed) > 0 {
+		if err := c.writeRouted(ctx, zone, routed, true); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// SetRecords replaces the records of zone sharing name and type with records
+func (c *Route53DnsClient) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	routed, plain := splitRoute53Records(records)
+	if len(plain) > 0 {
+		set, err := c.Provider.SetRecords(ctx, zone, plain)
+		if err != nil {
+			return set, c.explain(zone, err)
+		}
+	}
+	if len(routed) > 0 {
+		if err := c.writeRouted(ctx, zone, routed, false); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// DeleteRecords removes records from zone. Records of names with a routed
+// record set are removed from our set only.
+func (c *Route53DnsClient) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	if c.options.IsZero() {
+		deleted, err := c.Provider.DeleteRecords(ctx, zone, records)
+		return deleted, c.explain(zone, err)
+	}
+
+	sets, err := c.routedSets(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var plain []libdns.Record
+	var changes []types.Change
+	for _, set := range sets {
+		if aws.ToString(set.SetIdentifier) != c.options.SetIdentifier {
+			continue
+		}
+		if change, ok := route53DeleteChange(zone, set, records); ok {
+			changes = append(changes, change)
+		}
+	}
+	for _, record := range records {
+		if !route53Routed(zone, sets, record.RR()) {
+			plain = append(plain, record)
+		}
+	}
+
+	if len(plain) > 0 {
+		if _, err := c.Provider.DeleteRecords(ctx, zone, plain); err != nil {
+			return nil, c.explain(zone, err)
+		}
+	}
+	if err := c.change(ctx, zone, changes); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// explain wraps the errors the provider returns for zones without a hosted zone
+func (c *Route53DnsClient) explain(zone string, err error) error {
+	if err != nil && (strings.Contains(err.Error(), "HostedZoneNotFound") || strings.Contains(err.Error(), "NoSuchHostedZone")) {
+		return fmt.Errorf("no hosted zone for %s found: %w", zone, ErrZoneNotFound)
+	}
+	return err
+}
+
+// init creates the AWS SDK client with the settings of the provider
+func (c *Route53DnsClient) init(ctx context.Context) error {
+	c.once.Do(func() {
+		var opts []func(*config.LoadOptions) error
+		if c.Region != "" {
+			opts = append(opts, config.WithRegion(c.Region))
+		}
+		if c.AccessKeyId != "" && c.SecretAccessKey != "" {
+			opts = append(opts, config.WithCredentialsProvider(
+				credentials.NewStaticCredentialsProvider(c.AccessKeyId, c.SecretAccessKey, c.SessionToken)))
+		}
+
+		cfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			c.initErr = fmt.Errorf("error loading AWS configuration: %v", err)
+			return
+		}
+		c.client = r53.NewFromConfig(cfg)
+	})
+	return c.initErr
+}
+
+// zoneID returns the ID of the hosted zone serving zone
+func (c *Route53DnsClient) zoneID(ctx context.Context, zone string) (string, error) {
+	if c.HostedZoneID != "" {
+		return c.HostedZoneID, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id, ok := c.zoneIDs[zone]; ok {
+		return id, nil
+	}
+
+	name := strings.TrimSuffix(zone, ".") + "."
+	out, err := c.client.ListHostedZonesByName(ctx, &r53.ListHostedZonesByNameInput{DNSName: aws.String(name)})
+	if err != nil {
+		return "", err
+	}
+	for _, hostedZone := range out.HostedZones {
+		if strings.EqualFold(aws.ToString(hostedZone.Name), name) && !hostedZone.Config.PrivateZone {
+			if c.zoneIDs == nil {
+				c.zoneIDs = make(map[string]string)
+			}
+			c.zoneIDs[zone] = aws.ToString(hostedZone.Id)
+			return c.zoneIDs[zone], nil
+		}
+	}
+	return "", fmt.Errorf("no hosted zone for %s found: %w", zone, ErrZoneNotFound)
+}
+
+// routedSets returns the record sets of zone with a routing policy
+func (c *Route53DnsClient) routedSets(ctx context.Context, zone string) ([]types.ResourceRecordSet, error) {
+	if err := c.init(ctx); err != nil {
+		return nil, err
+	}
+	id, err := c.zoneID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []types.ResourceRecordSet
+	paginator := r53.NewListResourceRecordSetsPaginator(c.client, &r53.ListResourceRecordSetsInput{HostedZoneId: aws.String(id)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, c.explain(zone, err)
+		}
+		for _, set := range page.ResourceRecordSets {
+			if set.SetIdentifier != nil {
+				sets = append(sets, set)
+			}
+		}
+	}
+	return sets, nil
+}
+
+// writeRouted upserts our record sets with records. With merge the values
+// already in our set are kept.
+func (c *Route53DnsClient) writeRouted(ctx context.Context, zone string, records []route53RoutedRecord, merge bool) error {
+	var current []types.ResourceRecordSet
+	if merge {
+		sets, err := c.routedSets(ctx, zone)
+		if err != nil {
+			return err
+		}
+		current = sets
+	}
+	return c.change(ctx, zone, route53UpsertChanges(zone, records, current))
+}
+
+// change submits changes to the hosted zone serving zone
+func (c *Route53DnsClient) change(ctx context.Context, zone string, changes []types.Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := c.init(ctx); err != nil {
+		return err
+	}
+	id, err := c.zoneID(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.ChangeResourceRecordSets(ctx, &r53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(id),
+		ChangeBatch:  &types.ChangeBatch{Changes: changes},
+	})
+	return c.explain(zone, err)
+}
+
+// splitRoute53Records separates the records of routed record sets from plain records
+func splitRoute53Records(records []libdns.Record) ([]route53RoutedRecord, []libdns.Record) {
+	var routed []route53RoutedRecord
+	var plain []libdns.Record
+	for _, record := range records {
+		if r, ok := record.(route53RoutedRecord); ok {
+			routed = append(routed, r)
+		} else {
+			plain = append(plain, record)
+		}
+	}
+	return routed, plain
+}
+
+// route53Routed reports whether rr belongs to a name and type with routed record sets
+func route53Routed(zone string, sets []types.ResourceRecordSet, rr libdns.RR) bool {
+	for _, set := range sets {
+		if string(set.Type) == rr.Type && sameName(aws.ToString(set.Name), rr.Name, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// route53MergeRouted replaces the records of names with routed record sets by
+// the values of the set identified by setID
+func route53MergeRouted(zone string, records []libdns.Record, sets []types.ResourceRecordSet, setID string) []libdns.Record {
+	merged := slices.DeleteFunc(slices.Clone(records), func(record libdns.Record) bool {
+		return route53Routed(zone, sets, record.RR())
+	})
+	for _, set := range sets {
+		if aws.ToString(set.SetIdentifier) != setID {
+			continue
+		}
+		for _, value := range set.ResourceRecords {
+			merged = append(merged, libdns.RR{
+				Name: libdns.RelativeName(aws.ToString(set.Name), zone),
+				Type: string(set.Type),
+				Data: aws.ToString(value.Value),
+				TTL:  time.Duration(aws.ToInt64(set.TTL)) * time.Second,
+			})
+		}
+	}
+	return merged
+}
+
+// route53UpsertChanges builds the changes writing records into their record
+// sets, keeping the values of the matching sets in current
+func route53UpsertChanges(zone string, records []route53RoutedRecord, current []types.ResourceRecordSet) []types.Change {
+	var changes []types.Change
+	for _, record := range records {
+		rr := record.RR()
+		name := libdns.AbsoluteName(rr.Name, zone)
+
+		var set *types.ResourceRecordSet
+		for i := range changes {
+			s := changes[i].ResourceRecordSet
+			if string(s.Type) == rr.Type && sameName(aws.ToString(s.Name), name, zone) {
+				set = s
+				break
+			}
+		}
+		if set == nil {
+			set = route53RecordSet(name, rr, record.options)
+			for _, existing := range current {
+				if aws.ToString(existing.SetIdentifier) == record.options.SetIdentifier &&
+					string(existing.Type) == rr.Type && sameName(aws.ToString(existing.Name), name, zone) {
+					set.ResourceRecords = slices.Clone(existing.ResourceRecords)
+				}
+			}
+			changes = append(changes, types.Change{Action: types.ChangeActionUpsert, ResourceRecordSet: set})
+		}
+
+		if !slices.ContainsFunc(set.ResourceRecords, func(v types.ResourceRecord) bool {
+			return sameContent(rr.Type, aws.ToString(v.Value), rr.Data)
+		}) {
+			set.ResourceRecords = append(set.ResourceRecords, types.ResourceRecord{Value: aws.String(rr.Data)})
+		}
+	}
+	return changes
+}
+
+// route53RecordSet returns an empty record set for rr with the routing policy of options
+func route53RecordSet(name string, rr libdns.RR, options RecordOptions) *types.ResourceRecordSet {
+	set := &types.ResourceRecordSet{
+		Name:          aws.String(name),
+		Type:          types.RRType(rr.Type),
+		TTL:           aws.Int64(int64(rr.TTL.Seconds())),
+		SetIdentifier: aws.String(options.SetIdentifier),
+	}
+	if options.Failover != "" {
+		set.Failover = types.ResourceRecordSetFailover(options.Failover)
+	} else {
+		set.Weight = aws.Int64(options.Weight)
+	}
+	if options.HealthCheckID != "" {
+		set.HealthCheckId = aws.String(options.HealthCheckID)
+	}
+	return set
+}
+
+// route53DeleteChange builds the change removing records from set. Records
+// without data remove all values. ok is false if no value of set is removed.
+func route53DeleteChange(zone string, set types.ResourceRecordSet, records []libdns.Record) (types.Change, bool) {
+	kept := slices.DeleteFunc(slices.Clone(set.ResourceRecords), func(v types.ResourceRecord) bool {
+		for _, record := range records {
+			rr := record.RR()
+			if rr.Type == string(set.Type) && sameName(aws.ToString(set.Name), rr.Name, zone) &&
+				(rr.Data == "" || sameContent(rr.Type, aws.ToString(v.Value), rr.Data)) {
+				return true
+			}
+		}
+		return false
+	})
+
+	switch {
+	case len(kept) == len(set.ResourceRecords):
+		return types.Change{}, false
+	case len(kept) == 0:
+		return types.Change{Action: types.ChangeActionDelete, ResourceRecordSet: &set}, true
+	default:
+		set.ResourceRecords = kept
+		return types.Change{Action: types.ChangeActionUpsert, ResourceRecordSet: &set}, true
+	}
+}
@@ -4,32 +4,116 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"math/rand/v2"
 	"net/netip"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/libdns/bunny"
+	"github.com/libdns/desec"
+	"github.com/libdns/googleclouddns"
 	"github.com/libdns/inwx"
 	"github.com/libdns/libdns"
+	"github.com/libdns/namecheap"
+	"github.com/libdns/powerdns"
+	"github.com/libdns/route53"
+	"golang.org/x/time/rate"
 )
 
 const OrchestrationTypeDockerSwarm = "swarm"
 const OrchestrationTypeKubernetes = "kubernetes"
+const OrchestrationTypeNomad = "nomad"
+
+const RecordTypeAddress = "A"
+const RecordTypeCNAME = "CNAME"
+
+const ModeWatch = "watch"
+const ModeOneshot = "oneshot"
+const ModeMulti = "multi"
+const ModeCoordinator = "coordinator"
+
+// Values of SENTINEL_FORCE_LEADER, which overrides the leadership reported by
+// the orchestration adapter for maintenance and failover drills
+const ForceLeaderAuto = "auto"
+const ForceLeaderTrue = "true"
+const ForceLeaderFalse = "false"
 
 const DnsProviderInwx = "inwx"
 const DnsProviderBunny = "bunny"
+const DnsProviderNoop = "noop"
+const DnsProviderGcloud = "gcloud"
+const DnsProviderPowerDNS = "powerdns"
+const DnsProviderDesec = "desec"
+const DnsProviderNamecheap = "namecheap"
+const DnsProviderVultr = "vultr"
+const DnsProviderRoute53 = "route53"
 
 // Config holds the application configuration
 type Config struct {
-	Domain            string
-	Record            string
-	RecordTTL         int64
-	ServerIP          string
-	LogLevel          string
-	OrchestrationType string
-	DnsProvider       string // "inwx" or "bunny"
+	Domain             string
+	Zone               string // DNS zone managed through the provider, defaults to Domain
+	Records            []string
+	ExtraZones         []ZoneRecords // further domains whose records follow the leader
+	RecordTTL          int64
+	RecordTTLs         map[string]int64 // TTL overrides keyed by record, from SENTINEL_RECORD_TTL_<record>
+	ProviderTTL        bool             // write records without TTL, leaving it to the provider
+	ServerIP           string
+	ServerIPv6         string
+	LogLevel           string
+	OrchestrationType  string
+	DnsProvider        string // "inwx", "bunny", "gcloud", "powerdns", "desec", "namecheap", "vultr", "route53" or "noop"
+	ReconcileInterval  time.Duration
+	LeaderPollInterval time.Duration // poll IsLeader independently of events, 0 disables
+	CheckInterval      time.Duration // read-only drift checks between reconciles, 0 disables
+	TimerJitter        int64         // percentage the periodic timers are randomly spread by
+	LagThreshold       time.Duration // warn when a reconcile completes later than this after its trigger, 0 disables
+	DnsMaxRetries      int
+	DnsRetryBase       time.Duration
+	MetricsAddr        string
+	HealthAddr         string
+	IPSource           string
+	IPSourceOrder      []string // IP sources tried in order, overrides IPSource
+	IPEchoURL          string
+	DryRun             bool
+	Mode               string
+	K8sLeaderMode      string
+	K8sLeaseName       string
+	K8sLeaseNamespace  string
+	DockerHost         string
+	DockerTLSCert      string
+	DockerTLSKey       string
+	DockerTLSCA        string
+	RecordType         string // "A" (A/AAAA depending on the IP) or "CNAME"
+	CNAMETarget        string
+	CNAMETargetLabel   string
+	WebhookURL         string
+	EventLog           string // NDJSON event log: a path, "-" for stdout or "fd:<n>"
+	HTTPSProxy         string // overrides HTTPS_PROXY for outbound HTTP
+	PublicIPLabel      string
+	PublicIPv6Label    string
+	IPFamily           string   // "ipv4", "ipv6" or "dual"
+	IPTransform        string   // rewrites the server IP before publishing: "from=to" mappings or "exec:<program>"
+	ForceLeader        string   // "auto" asks the adapter, "true" or "false" pin leadership
+	DockerIPSources    []string // where the Docker adapter looks for the node IP, in order
+	AllowPrivateIP     bool
+	DebounceInterval   time.Duration
+	DnsTimeout         time.Duration
+	DnsRateLimit       int64         // DNS provider calls per minute, 0 disables the limit
+	RecordsCacheTTL    time.Duration // how long GetRecords results are reused, 0 disables the cache
+	LockFile           string        // flock held while updating DNS, empty disables it
+	LockTimeout        time.Duration
+	OwnerID            string
+	VerifyTimeout      time.Duration
+	WithdrawOnShutdown bool          // remove the records of this node when it shuts down as the leader
+	FallbackIP         string        // address the records point to on shutdown or without a leader
+	FallbackAfter      time.Duration // leader heartbeat age after which the coordinator falls back, 0 disables heartbeats
+	RecordOptions      RecordOptions
 }
 
 // Sentinel is the main application struct
@@ -37,54 +121,380 @@ type Sentinel struct {
 	Config        *Config
 	DnsClient     DnsClient
 	orchestration OrchestrationAdapter
+	ipSource      IPSource
+
+	// ipTransform rewrites the server IPs before they are published, nil if unset
+	ipTransform *ipTransform
+
+	// zoneClients holds the DNS clients of zones not served by DnsClient
+	zoneClients map[string]DnsClient
+
+	// mu serializes reconciles triggered by events and by the periodic ticker
+	mu sync.Mutex
+
+	// debouncePending is set while an event triggered check is scheduled,
+	// further events until then are coalesced into it
+	debounceMu      sync.Mutex
+	debouncePending bool
+
+	// triggeredAt is when the oldest event not yet covered by a reconcile arrived
+	triggerMu   sync.Mutex
+	triggeredAt time.Time
+
+	// forceUpdate requests the next check to rewrite all records even if they
+	// already match, forcing is set while that check runs
+	forceUpdate atomic.Bool
+	forcing     bool
+
+	// dnsLimiter spaces out DNS provider calls to stay within DnsRateLimit, nil if unlimited
+	dnsLimiter *rate.Limiter
+
+	// recordsCache holds recent GetRecords results, refreshRecords makes the
+	// next check bypass it for all zones to notice changes made outside of Sentinel
+	recordsCache   recordsCache
+	refreshRecords atomic.Bool
+
+	// events is the structured event log, nil if SENTINEL_EVENT_LOG is unset
+	events *eventLog
+
+	// dnsFailures counts DNS operations that failed after exhausting all retries
+	dnsFailures int
+
+	// initialized is set once the orchestration adapter and DNS client are set up,
+	// ready once the first CheckAndUpdateDNS pass succeeded
+	initialized atomic.Bool
+	ready       atomic.Bool
+
+	// nodeName is the name of the node this instance runs on
+	nodeName string
+
+	// wasLeader is the leadership state seen by the previous check
+	wasLeader bool
+
+	// lastHeartbeat is when this instance last wrote the leader heartbeat record
+	lastHeartbeat time.Time
+
+	// staticServerIP is set if the server IP was configured and must not be re-detected
+	staticServerIP bool
+
+	// staticServerIPv6 is set if the server IPv6 was configured and must not be read from a label
+	staticServerIPv6 bool
+
+	// published holds the records of this node per zone in multi mode, so they
+	// can be withdrawn when the node or its address goes away
+	published map[string][]libdns.Record
+
+	// providerTTLs maps a configured TTL to the TTL the provider normalized it to
+	providerTTLs map[time.Duration]time.Duration
+
+	// status is the outcome of the last check, guarded by its own mutex so the
+	// status endpoint does not block on a running check
+	statusMu      sync.Mutex
+	status        Status
+	statusRecords map[string][]StatusRecord // managed records as last read, per zone
 }
 
 // NewConfig creates a new Config from environment variables
 func NewConfig() (*Config, error) {
+	// Settings missing from the environment are taken from the optional config file
+	if path := os.Getenv("SENTINEL_CONFIG_FILE"); path != "" {
+		if err := loadConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+
 	domain := getEnv("DOMAIN", "example.com")
-	record := getEnv("RECORD", "lb")
+	zone := getEnv("ZONE", domain)
+	records := splitList(getEnv("RECORD", "lb"))
+	if len(records) == 0 {
+		// An empty record name manages the apex of the domain itself
+		records = []string{"@"}
+	}
 	logLevel := getEnv("LOG_LEVEL", "INFO")
 	orchestrationType := getEnv("ORCHESTRATION_TYPE", OrchestrationTypeDockerSwarm)
 	dnsProvider := getEnv("DNS_PROVIDER", DnsProviderInwx)
+	serverIP := getEnv("SERVER_IP", "")
+	serverIPv6 := getEnv("SERVER_IPV6", "")
+	// 0 or "default" leave the TTL to the provider, while unset uses Sentinel's
+	// default. Negative values are kept for Validate to reject.
+	var recordTTL int64
+	rawTTL := strings.TrimSpace(getEnv("RECORD_TTL", ""))
+	providerTTL := rawTTL == "0" || strings.EqualFold(rawTTL, "default")
+	if !providerTTL && rawTTL != "" {
+		ttl, err := strconv.ParseInt(rawTTL, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for SENTINEL_RECORD_TTL, expected seconds or \"default\"", rawTTL)
+		}
+		recordTTL = ttl
+	}
+	extraZones := parseExtraZones(getEnv("EXTRA_ZONES", ""), records)
+	recordTTLs := make(map[string]int64)
+	allRecords := slices.Clone(records)
+	for _, z := range extraZones {
+		allRecords = append(allRecords, z.Records...)
+	}
+	for _, record := range allRecords {
+		if ttl := getEnvInt("RECORD_TTL_"+envName(record), 0); ttl > 0 {
+			recordTTLs[record] = ttl
+		}
+	}
+	reconcileInterval := getEnvDuration("RECONCILE_INTERVAL", 5*time.Minute)
+	leaderPollInterval := getEnvDuration("LEADER_POLL_INTERVAL", 0)
+	checkInterval := getEnvDuration("CHECK_INTERVAL", 0)
+	timerJitter := getEnvInt("TIMER_JITTER", 10)
+	lagThreshold := getEnvDuration("RECONCILE_LAG_THRESHOLD", 30*time.Second)
+	dnsMaxRetries := getEnvInt("DNS_MAX_RETRIES", 3)
+	dnsRetryBase := getEnvDuration("DNS_RETRY_BASE", time.Second)
+	metricsAddr := getEnv("METRICS_ADDR", "")
+	healthAddr := getEnv("HEALTH_ADDR", "")
+	ipSource := getEnv("IP_SOURCE", IPSourceOrchestrator)
+	ipSourceOrder := splitList(strings.ToLower(getEnv("IP_SOURCE_ORDER", "")))
+	ipEchoURL := getEnv("IP_ECHO_URL", DefaultIPEchoURL)
+	dryRun := getEnvBool("DRY_RUN", false)
+	mode := getEnv("MODE", ModeWatch)
+	forceLeader := strings.ToLower(getEnv("FORCE_LEADER", ForceLeaderAuto))
+	k8sLeaderMode := getEnv("K8S_LEADER_MODE", K8sLeaderModeControllerManager)
+	k8sLeaseName := getEnv("K8S_LEASE_NAME", DefaultK8sControllerManagerLeaseName)
+	k8sLeaseNamespace := getEnv("K8S_LEASE_NAMESPACE", DefaultK8sControllerManagerLeaseNamespace)
+	if k8sLeaderMode == K8sLeaderModeSelf {
+		k8sLeaseName = getEnv("K8S_LEASE_NAME", DefaultK8sSelfLeaseName)
+		k8sLeaseNamespace = getEnv("K8S_LEASE_NAMESPACE", DefaultK8sSelfLeaseNamespace)
+	}
+	dockerHost := getEnv("DOCKER_HOST", DefaultDockerHost)
+	dockerTLSCert := getEnv("DOCKER_TLS_CERT", "")
+	dockerTLSKey := getEnv("DOCKER_TLS_KEY", "")
+	dockerTLSCA := getEnv("DOCKER_TLS_CA", "")
+	recordType := strings.ToUpper(getEnv("RECORD_TYPE", RecordTypeAddress))
+	cnameTarget := getEnv("CNAME_TARGET", "")
+	cnameTargetLabel := getEnv("CNAME_TARGET_LABEL", "")
+	webhookURL := getEnv("WEBHOOK_URL", "")
+	eventLog := getEnv("EVENT_LOG", "")
+	httpsProxy := getEnv("HTTPS_PROXY", "")
+	publicIPLabel := getEnv("PUBLIC_IP_LABEL", "public_ip")
+	publicIPv6Label := getEnv("PUBLIC_IPV6_LABEL", "")
+	ipFamily := strings.ToLower(getEnv("IP_FAMILY", IPFamilyDual))
+	ipTransform := getEnv("IP_TRANSFORM", "")
+	dockerIPSources := splitList(getEnv("DOCKER_IP_SOURCES", DockerIPSourceLabel+","+DockerIPSourceAddr))
+	allowPrivateIP := getEnvBool("ALLOW_PRIVATE_IP", false)
+	debounceInterval := getEnvDuration("DEBOUNCE_INTERVAL", 3*time.Second)
+	dnsTimeout := getEnvDuration("DNS_TIMEOUT", 30*time.Second)
+	dnsRateLimit := getEnvInt("DNS_RATE_LIMIT", 0)
+	recordsCacheTTL := getEnvDuration("RECORDS_CACHE_TTL", 0)
+	lockFile := getEnv("LOCK_FILE", "")
+	lockTimeout := getEnvDuration("LOCK_TIMEOUT", 10*time.Second)
+	ownerID := getEnv("OWNER_ID", "")
+	verifyTimeout := getEnvDuration("VERIFY_TIMEOUT", 0)
+	withdrawOnShutdown := getEnvBool("WITHDRAW_ON_SHUTDOWN", false)
+	fallbackIP := getEnv("FALLBACK_IP", "")
+	fallbackAfter := getEnvDuration("FALLBACK_AFTER", 0)
+	recordOptions := RecordOptions{
+		Weight:        getEnvInt("RECORD_WEIGHT", 0),
+		Failover:      strings.ToUpper(getEnv("RECORD_FAILOVER", "")),
+		SetIdentifier: getEnv("RECORD_SET_ID", ""),
+		HealthCheckID: getEnv("RECORD_HEALTH_CHECK_ID", ""),
+	}
 
 	config := &Config{
-		Domain:            domain,
-		Record:            record,
-		LogLevel:          logLevel,
-		OrchestrationType: orchestrationType,
-		DnsProvider:       dnsProvider,
+		Domain:             domain,
+		Zone:               zone,
+		Records:            records,
+		ExtraZones:         extraZones,
+		RecordTTL:          recordTTL,
+		RecordTTLs:         recordTTLs,
+		ProviderTTL:        providerTTL,
+		ServerIP:           serverIP,
+		ServerIPv6:         serverIPv6,
+		LogLevel:           logLevel,
+		OrchestrationType:  orchestrationType,
+		DnsProvider:        dnsProvider,
+		ReconcileInterval:  reconcileInterval,
+		LeaderPollInterval: leaderPollInterval,
+		CheckInterval:      checkInterval,
+		TimerJitter:        timerJitter,
+		LagThreshold:       lagThreshold,
+		DnsMaxRetries:      int(dnsMaxRetries),
+		DnsRetryBase:       dnsRetryBase,
+		MetricsAddr:        metricsAddr,
+		HealthAddr:         healthAddr,
+		IPSource:           ipSource,
+		IPSourceOrder:      ipSourceOrder,
+		IPEchoURL:          ipEchoURL,
+		DryRun:             dryRun,
+		Mode:               mode,
+		ForceLeader:        forceLeader,
+		K8sLeaderMode:      k8sLeaderMode,
+		K8sLeaseName:       k8sLeaseName,
+		K8sLeaseNamespace:  k8sLeaseNamespace,
+		DockerHost:         dockerHost,
+		DockerTLSCert:      dockerTLSCert,
+		DockerTLSKey:       dockerTLSKey,
+		DockerTLSCA:        dockerTLSCA,
+		RecordType:         recordType,
+		CNAMETarget:        cnameTarget,
+		CNAMETargetLabel:   cnameTargetLabel,
+		WebhookURL:         webhookURL,
+		EventLog:           eventLog,
+		HTTPSProxy:         httpsProxy,
+		PublicIPLabel:      publicIPLabel,
+		PublicIPv6Label:    publicIPv6Label,
+		IPFamily:           ipFamily,
+		IPTransform:        ipTransform,
+		DockerIPSources:    dockerIPSources,
+		AllowPrivateIP:     allowPrivateIP,
+		DebounceInterval:   debounceInterval,
+		DnsTimeout:         dnsTimeout,
+		DnsRateLimit:       dnsRateLimit,
+		RecordsCacheTTL:    recordsCacheTTL,
+		LockFile:           lockFile,
+		LockTimeout:        lockTimeout,
+		OwnerID:            ownerID,
+		VerifyTimeout:      verifyTimeout,
+		WithdrawOnShutdown: withdrawOnShutdown,
+		FallbackIP:         fallbackIP,
+		FallbackAfter:      fallbackAfter,
+		RecordOptions:      recordOptions,
 	}
 
 	return config, nil
 }
 
-func configureInwx(c *Config) (*inwx.Provider, error) {
-	c.RecordTTL = 300
+// buildDnsClient creates the client of the named DNS provider
+func buildDnsClient(provider string, config *Config) (DnsClient, error) {
+	var dnsClient DnsClient
+	var err error
+	switch provider {
+	case DnsProviderInwx:
+		dnsClient, err = configureInwx(config)
+	case DnsProviderBunny:
+		dnsClient, err = configureBunny(config)
+	case DnsProviderGcloud:
+		dnsClient, err = configureGcloud(config)
+	case DnsProviderPowerDNS:
+		dnsClient, err = configurePowerDNS(config)
+	case DnsProviderDesec:
+		dnsClient, err = configureDesec(config)
+	case DnsProviderNamecheap:
+		dnsClient, err = configureNamecheap(config)
+	case DnsProviderVultr:
+		dnsClient, err = configureVultr(config)
+	case DnsProviderRoute53:
+		dnsClient, err = configureRoute53(config)
+	case DnsProviderNoop:
+		dnsClient, err = configureNoop(config)
+	default:
+		// Validate rejects unknown providers already, this covers callers skipping it
+		return nil, fmt.Errorf("unsupported DNS provider %q (supported: %s)", provider, strings.Join(supportedDnsProviders, ", "))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error configuring DNS provider %s: %v", provider, err)
+	}
+	return dnsClient, nil
+}
+
+// providerMinTTLs is the lowest TTL in seconds each DNS provider accepts.
+// Providers not listed accept any TTL.
+var providerMinTTLs = map[string]int64{
+	DnsProviderInwx:      300,
+	DnsProviderBunny:     15,
+	DnsProviderDesec:     desecMinTTL,
+	DnsProviderNamecheap: 60,
+}
 
-	inwxUser := getEnv("INWX_USER", "")
+// applyProviderTTL sets defaultTTL if no TTL is configured and raises TTLs
+// below the minimum of provider, which would otherwise be rejected or silently
+// changed by the provider, making failover times unpredictable. With
+// ProviderTTL set the record TTL stays 0, only overrides are checked.
+func applyProviderTTL(c *Config, provider string, defaultTTL int64) {
+	minTTL := providerMinTTLs[provider]
+	if !c.ProviderTTL {
+		if c.RecordTTL == 0 {
+			c.RecordTTL = defaultTTL
+		}
+		if c.RecordTTL < minTTL {
+			logger.Warnf("DNS provider %s requires a TTL of at least %ds, raising SENTINEL_RECORD_TTL from %d", provider, minTTL, c.RecordTTL)
+			c.RecordTTL = minTTL
+		}
+	}
+	for record, ttl := range c.RecordTTLs {
+		if ttl < minTTL {
+			logger.Warnf("DNS provider %s requires a TTL of at least %ds, raising the TTL of record %s from %d", provider, minTTL, record, ttl)
+			c.RecordTTLs[record] = minTTL
+		}
+	}
+}
+
+// dnsClient returns the DNS client managing zone
+func (s *Sentinel) dnsClient(zone string) DnsClient {
+	if client, ok := s.zoneClients[zone]; ok {
+		return client
+	}
+	return s.DnsClient
+}
+
+func configureInwx(c *Config) (*InwxDnsClient, error) {
+	applyProviderTTL(c, DnsProviderInwx, 300)
+
+	inwxUser, err := getSecret("INWX_USER")
+	if err != nil {
+		return nil, err
+	}
 
 	if inwxUser == "" {
 		return nil, fmt.Errorf("INWX_USER not set")
 	}
 
-	inwxPassword, err := readSecret("/run/secrets/inwx_password")
+	inwxPassword, err := getSecret("INWX_PASSWORD")
 	if err != nil {
-		inwxPassword = getEnv("INWX_PASSWORD", "")
-		if inwxPassword == "" {
+		return nil, err
+	}
+
+	if inwxPassword == "" {
+		// Default mount point of the inwx_password Docker secret
+		inwxPassword, err = readSecret("/run/secrets/inwx_password")
+		if err != nil {
 			return nil, fmt.Errorf("INWX_PASSWORD not set and could not read from secret: %v", err)
 		}
 	}
 
-	return &inwx.Provider{
-		Username: inwxUser,
-		Password: inwxPassword,
+	// Allows pointing Sentinel at the INWX OT&E sandbox instead of production
+	inwxEndpoint := getEnv("INWX_ENDPOINT", "")
+	if inwxEndpoint != "" {
+		logger.Infof("Using INWX endpoint %s", inwxEndpoint)
+	}
+
+	// Accounts with two-factor authentication are unlocked with a TOTP code
+	// generated from the shared secret shown when 2FA was set up
+	totpSecret, err := getSecret("INWX_TOTP_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	if totpSecret != "" {
+		totpSecret, err = normalizeTOTPSecret(totpSecret)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The provider logs in and out around every API call, so there is no
+	// long-lived session that could expire between reconciles
+	return &InwxDnsClient{
+		Provider: &inwx.Provider{
+			Username:     inwxUser,
+			Password:     inwxPassword,
+			SharedSecret: totpSecret,
+			EndpointURL:  inwxEndpoint,
+		},
 	}, nil
 }
 
 func configureBunny(c *Config) (*bunny.Provider, error) {
-	c.RecordTTL = 15
+	applyProviderTTL(c, DnsProviderBunny, 15)
 
-	bunnyAPIKey := getEnv("BUNNY_API_KEY", "")
+	bunnyAPIKey, err := getSecret("BUNNY_API_KEY")
+	if err != nil {
+		return nil, err
+	}
 
 	if bunnyAPIKey == "" {
 		return nil, fmt.Errorf("BUNNY_API_KEY not set")
@@ -95,115 +505,1005 @@ func configureBunny(c *Config) (*bunny.Provider, error) {
 	}, nil
 }
 
-// NewSentinel creates a new Sentinel instance
-func NewSentinel(config *Config) *Sentinel {
+func configureGcloud(c *Config) (*GcloudDnsClient, error) {
+	applyProviderTTL(c, DnsProviderGcloud, 300)
+
+	project := getEnv("GCLOUD_PROJECT", "")
+	if project == "" {
+		return nil, fmt.Errorf("GCLOUD_PROJECT not set, it must name the Google Cloud project hosting the zone")
+	}
+
+	// The provider reads the service account key from a file, an inline key is
+	// written to a private temporary one. Without a key the default credentials
+	// are used, i.e. GOOGLE_APPLICATION_CREDENTIALS or the GKE metadata server.
+	saFile := getEnv("GCLOUD_SA_JSON_FILE", "")
+	if saJSON := getEnv("GCLOUD_SA_JSON", ""); saFile == "" && saJSON != "" {
+		file, err := os.CreateTemp("", "gcloud-sa-*.json")
+		if err != nil {
+			return nil, fmt.Errorf("could not store GCLOUD_SA_JSON, mount the key and set GCLOUD_SA_JSON_FILE instead: %v", err)
+		}
+		defer file.Close()
+		if _, err := file.WriteString(saJSON); err != nil {
+			return nil, fmt.Errorf("could not store GCLOUD_SA_JSON: %v", err)
+		}
+		saFile = file.Name()
+	}
+
+	return &GcloudDnsClient{
+		Provider: &googleclouddns.Provider{
+			Project:            project,
+			ServiceAccountJSON: saFile,
+		},
+	}, nil
+}
+
+func configurePowerDNS(c *Config) (*PowerDnsClient, error) {
+	// The API has no default TTL, every rrset is written with one
+	if c.ProviderTTL {
+		logger.Warnf("DNS provider %s has no default TTL, using 60s", DnsProviderPowerDNS)
+		c.ProviderTTL = false
+	}
+
+	// Self-hosted servers are usually authoritative themselves, so a short TTL
+	// lets resolvers pick up a failover quickly
+	applyProviderTTL(c, DnsProviderPowerDNS, 60)
+
+	serverURL := getEnv("POWERDNS_SERVER_URL", "")
+	if serverURL == "" {
+		return nil, fmt.Errorf("POWERDNS_SERVER_URL not set")
+	}
+	if u, err := url.Parse(serverURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("POWERDNS_SERVER_URL %q is not a valid URL", serverURL)
+	}
+
+	apiToken, err := getSecret("POWERDNS_API_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	if apiToken == "" {
+		return nil, fmt.Errorf("POWERDNS_API_TOKEN not set")
+	}
+
+	return &PowerDnsClient{
+		Provider: &powerdns.Provider{
+			ServerURL: serverURL,
+			ServerID:  getEnv("POWERDNS_SERVER_ID", "localhost"),
+			APIToken:  apiToken,
+		},
+	}, nil
+}
+
+func configureDesec(c *Config) (*DesecDnsClient, error) {
+	applyProviderTTL(c, DnsProviderDesec, desecMinTTL)
+
+	token, err := getSecret("DESEC_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+
+	if token == "" {
+		// Default mount point of the desec_token Docker secret
+		token, err = readSecret("/run/secrets/desec_token")
+		if err != nil {
+			return nil, fmt.Errorf("DESEC_TOKEN not set and could not read from secret: %v", err)
+		}
+	}
+
+	return &DesecDnsClient{Provider: &desec.Provider{Token: token}}, nil
+}
+
+func configureNamecheap(c *Config) (*NamecheapDnsClient, error) {
+	applyProviderTTL(c, DnsProviderNamecheap, 300)
+
+	apiUser := getEnv("NAMECHEAP_API_USER", "")
+	if apiUser == "" {
+		return nil, fmt.Errorf("NAMECHEAP_API_USER not set")
+	}
+
+	apiKey, err := getSecret("NAMECHEAP_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("NAMECHEAP_API_KEY not set")
+	}
+
+	// Namecheap rejects API requests that do not name the whitelisted IP they come from
+	clientIP := getEnv("NAMECHEAP_CLIENT_IP", "")
+	if clientIP == "" {
+		return nil, fmt.Errorf("NAMECHEAP_CLIENT_IP not set, it must be the IP whitelisted for the Namecheap API")
+	}
+	if _, err := netip.ParseAddr(clientIP); err != nil {
+		return nil, fmt.Errorf("NAMECHEAP_CLIENT_IP %q is not a valid IP address", clientIP)
+	}
+
+	return &NamecheapDnsClient{
+		Provider: &namecheap.Provider{
+			APIKey:   apiKey,
+			User:     apiUser,
+			ClientIP: clientIP,
+		},
+	}, nil
+}
+
+func configureVultr(c *Config) (*VultrDnsClient, error) {
+	applyProviderTTL(c, DnsProviderVultr, 60)
+
+	apiKey, err := getSecret("VULTR_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("VULTR_API_KEY not set")
+	}
+
+	return NewVultrDnsClient(apiKey), nil
+}
+
+func configureRoute53(c *Config) (*Route53DnsClient, error) {
+	// Route 53 requires a TTL on every record set
+	if c.ProviderTTL {
+		logger.Warnf("DNS provider %s has no default TTL, using 300s", DnsProviderRoute53)
+		c.ProviderTTL = false
+	}
+	applyProviderTTL(c, DnsProviderRoute53, 300)
+
+	// Without keys the AWS SDK falls back to its default credential chain,
+	// e.g. AWS_ACCESS_KEY_ID, shared profiles or instance roles
+	accessKeyID := getEnv("ROUTE53_ACCESS_KEY_ID", "")
+	secretAccessKey, err := getSecret("ROUTE53_SECRET_ACCESS_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if (accessKeyID == "") != (secretAccessKey == "") {
+		return nil, fmt.Errorf("ROUTE53_ACCESS_KEY_ID and ROUTE53_SECRET_ACCESS_KEY must be set together")
+	}
+
+	return &Route53DnsClient{
+		Provider: &route53.Provider{
+			Region:          getEnv("ROUTE53_REGION", "us-east-1"),
+			AccessKeyId:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			HostedZoneID:    getEnv("ROUTE53_HOSTED_ZONE_ID", ""),
+		},
+		options: c.RecordOptions,
+	}, nil
+}
+
+func configureNoop(c *Config) (*NoopDnsClient, error) {
+	applyProviderTTL(c, DnsProviderNoop, 60)
+
+	logger.Warnf("Using the noop DNS provider, records are only kept in memory")
+	return NewNoopDnsClient(), nil
+}
+
+// NewSentinel creates a new Sentinel instance, it returns an error if the DNS
+// provider or the orchestration cannot be set up
+func NewSentinel(config *Config) (*Sentinel, error) {
 	sentinel := &Sentinel{
 		Config: config,
 	}
-
-	var dnsClient DnsClient
-	var err error
-	switch config.DnsProvider {
-	case DnsProviderInwx:
-		dnsClient, err = configureInwx(config)
-	case DnsProviderBunny:
-		dnsClient, err = configureBunny(config)
-	default:
-		err = errors.New("Unsupported DNS provider: " + config.DnsProvider)
+	if config.DnsRateLimit > 0 {
+		sentinel.dnsLimiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(config.DnsRateLimit)), 1)
+	}
+	if config.EventLog != "" {
+		events, err := openEventLog(config.EventLog)
+		if err != nil {
+			return nil, err
+		}
+		sentinel.events = events
 	}
 
+	transform, err := parseIPTransform(config.IPTransform)
 	if err != nil {
-		log.Fatalf("Error configuring DNS provider%s: %v", config.DnsProvider, err)
+		return nil, err
 	}
+	sentinel.ipTransform = transform
 
+	dnsClient, err := buildDnsClient(config.DnsProvider, config)
+	if err != nil {
+		return nil, err
+	}
 	sentinel.DnsClient = dnsClient
 
+	// Extra zones may live at another provider, clients are shared per provider
+	clients := map[string]DnsClient{config.DnsProvider: dnsClient}
+	for _, z := range config.ExtraZones {
+		if z.DnsProvider == "" || z.DnsProvider == config.DnsProvider {
+			continue
+		}
+		client, ok := clients[z.DnsProvider]
+		if !ok {
+			client, err = buildDnsClient(z.DnsProvider, config)
+			if err != nil {
+				return nil, err
+			}
+			clients[z.DnsProvider] = client
+		}
+		if sentinel.zoneClients == nil {
+			sentinel.zoneClients = make(map[string]DnsClient)
+		}
+		sentinel.zoneClients[z.zone()] = client
+	}
+
+	for provider, client := range clients {
+		if _, ok := client.(RecordDecorator); !ok && !config.RecordOptions.IsZero() {
+			logger.Warnf("DNS provider %s does not support weighted or failover records, ignoring SENTINEL_RECORD_WEIGHT/FAILOVER/SET_ID/HEALTH_CHECK_ID", provider)
+		}
+	}
+
+	// A mistyped domain would otherwise only show up as failing updates
+	zoneCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := sentinel.checkZones(zoneCtx); err != nil {
+		return nil, err
+	}
+
+	// The coordinator only watches the leader heartbeat in DNS and does not
+	// take part in leader election, so it needs no orchestration or IP
+	if config.Mode == ModeCoordinator {
+		sentinel.initialized.Store(true)
+		return sentinel, nil
+	}
+
 	if config.OrchestrationType == OrchestrationTypeDockerSwarm {
-		sentinel.orchestration = NewDockerClient()
+		dockerAdapter, err := NewDockerClient(config)
+		if err != nil {
+			return nil, fmt.Errorf("error creating Docker orchestration: %v", err)
+		}
+		sentinel.orchestration = dockerAdapter
 	} else if config.OrchestrationType == OrchestrationTypeKubernetes {
-		k8sAdapter, err := NewK8sClient()
+		k8sAdapter, err := NewK8sClient(config)
 		if err != nil {
-			log.Fatalf("Error creating Kubernetes orchestration: %v", err)
+			return nil, fmt.Errorf("error creating Kubernetes orchestration: %v", err)
 		}
 		sentinel.orchestration = k8sAdapter
+	} else if config.OrchestrationType == OrchestrationTypeNomad {
+		sentinel.orchestration = NewNomadClient(config)
 	}
 
-	serverIP, err := sentinel.orchestration.GetNodePublicIP()
+	// CNAME records point to a host name, so no IP needs to be detected
+	if config.RecordType == RecordTypeCNAME {
+		if config.CNAMETarget == "" {
+			target, err := sentinel.resolveCNAMETarget()
+			if err != nil {
+				return nil, fmt.Errorf("could not get CNAME target: %v", err)
+			}
+			sentinel.Config.CNAMETarget = target
+		}
+	} else if config.ServerIP != "" {
+		// A statically configured IP skips the detection entirely
+		logger.Infof("Using configured server IP %s", config.ServerIP)
+		sentinel.staticServerIP = true
+	} else {
+		// The IP is only detected before updating DNS, so nodes that never
+		// lead keep running when their IP cannot be determined
+		ipSource, err := newIPSource(config, sentinel.orchestration)
+		if err != nil {
+			return nil, fmt.Errorf("error creating IP source %s: %v", config.ipSourceNames(), err)
+		}
+		sentinel.ipSource = ipSource
+	}
+
+	if config.ServerIPv6 != "" {
+		sentinel.staticServerIPv6 = true
+	} else if config.PublicIPv6Label != "" {
+		sentinel.refreshServerIPv6()
+	}
+	sentinel.initialized.Store(true)
+
+	return sentinel, nil
+}
+
+// resolveCNAMETarget reads the CNAME target from the configured label of the current node
+func (s *Sentinel) resolveCNAMETarget() (string, error) {
+	labelReader, ok := s.orchestration.(NodeLabelReader)
+	if !ok {
+		return "", fmt.Errorf("orchestration %s cannot read node labels", s.Config.OrchestrationType)
+	}
+	return labelReader.GetCurrentNodeLabel(s.Config.CNAMETargetLabel)
+}
+
+// refreshCNAMETarget re-reads a label based CNAME target, so label changes are published
+func (s *Sentinel) refreshCNAMETarget() {
+	if s.Config.CNAMETargetLabel == "" {
+		return
+	}
+
+	target, err := s.resolveCNAMETarget()
 	if err != nil {
-		log.Fatalf("Error: Could not get public IP: %v", err)
+		logger.Warnf("Could not refresh CNAME target, keeping %s: %v", s.Config.CNAMETarget, err)
+		return
 	}
-	sentinel.Config.ServerIP = serverIP
 
-	return sentinel
+	if target != s.Config.CNAMETarget {
+		logger.Infof("CNAME target changed: %s -> %s", s.Config.CNAMETarget, target)
+		s.Config.CNAMETarget = target
+	}
 }
 
-// CheckAndUpdateDNS checks if this node is the leader and updates DNS if needed
-func (s *Sentinel) CheckAndUpdateDNS() {
-	if s.orchestration.IsLeader() {
-		log.Println("This instance is the Leader")
-		s.updateDNS()
+// refreshServerIP re-detects the public IP, so address changes of the node are
+// published even if the leader stays the same
+func (s *Sentinel) refreshServerIP() {
+	if s.staticServerIP {
+		return
+	}
+
+	ips, err := s.ipSource.PublicIPs()
+	if err != nil {
+		logger.Warnf("Could not refresh public IP, keeping %s: %v", s.Config.ServerIP, err)
+		return
+	}
+	s.setServerIPs(ips)
+}
+
+// setServerIPs stores the addresses detected by the IP source. ServerIP holds
+// the IPv4 address, or the IPv6 address on IPv6-only nodes.
+func (s *Sentinel) setServerIPs(ips map[string]string) {
+	serverIP := ips[IPFamilyIPv4]
+	if serverIP == "" {
+		serverIP = ips[IPFamilyIPv6]
+	}
+	if serverIP != s.Config.ServerIP {
+		if s.Config.ServerIP != "" {
+			logger.Infof("Public IP changed: %s -> %s", s.Config.ServerIP, serverIP)
+		}
+		s.Config.ServerIP = serverIP
+	}
+
+	if serverIPv6 := ips[IPFamilyIPv6]; !s.staticServerIPv6 && serverIPv6 != "" && serverIPv6 != s.Config.ServerIPv6 {
+		if s.Config.ServerIPv6 != "" {
+			logger.Infof("Public IPv6 changed: %s -> %s", s.Config.ServerIPv6, serverIPv6)
+		}
+		s.Config.ServerIPv6 = serverIPv6
+	}
+}
+
+// refreshServerIPv6 re-reads the IPv6 address from the configured node label, if any
+func (s *Sentinel) refreshServerIPv6() {
+	if s.staticServerIPv6 || s.Config.PublicIPv6Label == "" {
+		return
+	}
+
+	labelReader, ok := s.orchestration.(NodeLabelReader)
+	if !ok {
+		logger.Warnf("Orchestration %s cannot read node labels, no IPv6 address is published", s.Config.OrchestrationType)
+		return
+	}
+
+	serverIPv6, err := labelReader.GetCurrentNodeLabel(s.Config.PublicIPv6Label)
+	if err != nil {
+		logger.Warnf("Could not read IPv6 label %s, keeping %q: %v", s.Config.PublicIPv6Label, s.Config.ServerIPv6, err)
+		return
+	}
+
+	if serverIPv6 != s.Config.ServerIPv6 {
+		logger.Infof("Public IPv6 changed: %s -> %s", s.Config.ServerIPv6, serverIPv6)
+		s.Config.ServerIPv6 = serverIPv6
 	}
 }
 
-func (s *Sentinel) updateDNS() {
-	ctx := context.Background()
-	zone := s.Config.Domain + "."
+// CheckAndUpdateDNS checks if this node is the leader and updates DNS if needed
+func (s *Sentinel) CheckAndUpdateDNS(ctx context.Context) (err error) {
+	ctx, span := s.reconcileSpan(ctx)
+	defer func() { endSpan(span, err) }()
+
+	if s.Config.Mode == ModeCoordinator {
+		return s.coordinate(ctx)
+	}
+
+	start := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Events up to now are covered, as this reconcile reads the current state
+	triggered := s.takeTrigger(start)
+	defer s.observeLag(triggered)
 
-	records, err := s.DnsClient.GetRecords(ctx, zone)
+	isLeader, err := s.checkLeader(ctx)
+	defer func() { s.recordStatus(isLeader, err) }()
 	if err != nil {
-		log.Printf("Could not get DNS records: %v", err)
+		// Treating this as "not leader" would make every node skip the update
+		// during an orchestrator outage, so keep the last known DNS state instead
+		logger.Errorf("Could not determine leadership, skipping DNS update: %v", err)
+		return err
+	}
+	leaderGauge.Set(boolToFloat(isLeader))
+
+	// Only transitions are logged, the check itself runs on every event
+	if isLeader && !s.wasLeader {
+		logger.Infof("Acquired leadership")
+		s.notifyWebhook(WebhookEvent{Event: WebhookEventLeaderAcquired})
+		s.emitEvent(Event{Event: EventLeaderAcquired})
+	} else if !isLeader && s.wasLeader {
+		logger.Infof("Lost leadership")
+		s.emitEvent(Event{Event: EventLeaderLost})
+	}
+	s.wasLeader = isLeader
+
+	publish := isLeader
+	if s.Config.Mode == ModeMulti {
+		// Every ready node publishes its own address next to the others
+		publish, err = s.isNodeReady()
+		if err != nil {
+			logger.Errorf("Could not determine node readiness, skipping DNS update: %v", err)
+			return err
+		}
+		if !publish {
+			if err := s.withdrawOwnRecords(ctx, "Node is not ready"); err != nil {
+				logger.Errorf("Could not withdraw records of this node: %v", err)
+				return err
+			}
+		}
+	}
+
+	if publish {
+		if isLeader {
+			logger.Debugf("This instance is the Leader")
+		}
+		if s.Config.RecordType == RecordTypeCNAME {
+			s.refreshCNAMETarget()
+		} else {
+			s.refreshServerIP()
+			s.refreshServerIPv6()
+			if len(s.serverAddrs()) == 0 {
+				err = fmt.Errorf("no public IP of family %s known for this node", s.Config.IPFamily)
+				logger.Errorf("Skipping DNS update: %v", err)
+				return err
+			}
+		}
+		if err := s.updateDNS(ctx); err != nil {
+			logger.Errorf("DNS update incomplete: %v", err)
+			return err
+		}
+	}
+
+	// Only a leader that just updated DNS successfully counts as healthy
+	if isLeader {
+		s.stampHeartbeat(ctx)
+	}
+
+	if !s.ready.Swap(true) {
+		logger.Infof("First DNS check completed, Sentinel is ready")
+	}
+	return nil
+}
+
+// ForceUpdateDNS runs a check that rewrites all records even if they already match
+func (s *Sentinel) ForceUpdateDNS(ctx context.Context) error {
+	s.forceUpdate.Store(true)
+	s.refreshRecords.Store(true)
+	return s.CheckAndUpdateDNS(ctx)
+}
+
+// onEvent is the callback for orchestration events, errors are already logged by CheckAndUpdateDNS.
+// Bursts of events, e.g. lease churn during control plane restarts, are collapsed into
+// a single check after DebounceInterval, which then sees the latest state.
+func (s *Sentinel) onEvent(ctx context.Context) {
+	s.markTriggered()
+	if s.Config.DebounceInterval <= 0 {
+		_ = s.CheckAndUpdateDNS(ctx)
+		return
+	}
+
+	s.debounceMu.Lock()
+	defer s.debounceMu.Unlock()
+
+	if s.debouncePending {
+		logger.Debugf("Coalescing event into pending DNS check")
 		return
 	}
+	s.debouncePending = true
+
+	time.AfterFunc(s.Config.DebounceInterval, func() {
+		s.debounceMu.Lock()
+		s.debouncePending = false
+		s.debounceMu.Unlock()
+
+		if ctx.Err() == nil {
+			_ = s.CheckAndUpdateDNS(ctx)
+		}
+	})
+}
+
+// recordResult describes the outcome of reconciling a single DNS record
+type recordResult int
+
+const (
+	recordUnchanged recordResult = iota
+	recordUpdated
+	recordFailed
+	recordDryRun
+)
+
+// recordTTL returns the TTL of the record with the zone relative name in z, which
+// is its override if one is configured and the global record TTL otherwise
+func (s *Sentinel) recordTTL(z ZoneRecords, name string) time.Duration {
+	zone := z.zone()
+	for i, relative := range z.recordNames() {
+		if ttl, ok := s.Config.RecordTTLs[z.Records[i]]; ok && sameName(relative, name, zone) {
+			return time.Duration(ttl) * time.Second
+		}
+	}
+	return time.Duration(s.Config.RecordTTL) * time.Second
+}
+
+// updateDNS reconciles the records of all zones, a failing zone does not keep
+// the others from being updated
+func (s *Sentinel) updateDNS(ctx context.Context) error {
+	// Another Sentinel on this host holding the lock is updating the same
+	// records, this reconcile is skipped instead of racing it
+	if s.Config.LockFile != "" {
+		unlock, err := acquireFileLock(ctx, s.Config.LockFile, s.Config.LockTimeout)
+		if errors.Is(err, errLockTimeout) {
+			logger.Warnf("Lock file %s is held by another process for %s, skipping this reconcile", s.Config.LockFile, s.Config.LockTimeout)
+			s.emitEvent(Event{Event: EventReconciled, Result: EventResultSkipped})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	s.forcing = s.forceUpdate.Swap(false)
+	refresh := s.refreshRecords.Swap(false)
+
+	var errs []error
+	for _, z := range s.zones() {
+		if err := s.updateZone(ctx, z, refresh); err != nil {
+			errs = append(errs, fmt.Errorf("zone %s: %w", z.zone(), err))
+		}
+	}
+
+	if len(errs) > 0 {
+		s.emitEvent(Event{Event: EventReconciled, Result: EventResultFailed})
+	} else {
+		s.emitEvent(Event{Event: EventReconciled, Result: EventResultSucceeded})
+	}
+
+	if len(errs) == 1 {
+		return errors.Unwrap(errs[0])
+	}
+	return errors.Join(errs...)
+}
+
+// updateZone reconciles the records of a single zone, refresh bypasses the records cache
+func (s *Sentinel) updateZone(ctx context.Context, z ZoneRecords, refresh bool) error {
+	zone := z.zone()
+
+	records, err := s.getRecords(ctx, zone, refresh)
+	if err != nil {
+		reconcileErr := s.reconcileError("get records", zone, "", err)
+		logger.Errorf("Could not get DNS records: %v", reconcileErr)
+		return reconcileErr
+	}
+	s.recordStatusRecords(z, records)
+
+	// Reconcile every record and address family on its own, so a failing
+	// update does not prevent the remaining records from being updated
+	var updated, unchanged, failed, skipped int
+	var desiredAll []libdns.Record
+	for _, name := range z.recordNames() {
+		if s.Config.OwnerID != "" && !s.claimOwnership(ctx, zone, records, name) {
+			failed++
+			continue
+		}
+
+		for _, desired := range s.desiredRecords(name, s.recordTTL(z, name)) {
+			desiredAll = append(desiredAll, desired)
+
+			var result recordResult
+			if s.Config.Mode == ModeMulti {
+				result = s.addRecord(ctx, zone, records, desired)
+			} else {
+				result = s.updateRecord(ctx, zone, records, desired)
+			}
+
+			switch result {
+			case recordUpdated:
+				updated++
+			case recordUnchanged:
+				unchanged++
+			case recordFailed:
+				failed++
+			case recordDryRun:
+				skipped++
+			}
+		}
+	}
+
+	if s.Config.Mode == ModeMulti {
+		if err := s.withdrawStaleRecords(ctx, zone, desiredAll); err != nil {
+			failed++
+		}
+		if s.wasLeader {
+			if err := s.syncReadyNodes(ctx, z, records); err != nil {
+				failed++
+			}
+		}
+	}
+
+	if s.Config.DryRun {
+		logger.Infof("DNS reconcile of %s finished (dry run): %d would be updated, %d already correct", zone, skipped, unchanged)
+	} else {
+		logger.Infof("DNS reconcile of %s finished: %d updated, %d already correct, %d failed", zone, updated, unchanged, failed)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d record updates failed", failed, updated+unchanged+failed)
+	}
+	return nil
+}
+
+// serverAddrs returns the parsed public addresses of the configured IP families
+// this instance should publish, after SENTINEL_IP_TRANSFORM rewrote them
+func (s *Sentinel) serverAddrs() []netip.Addr {
+	var addrs []netip.Addr
+	for _, raw := range []string{s.Config.ServerIP, s.Config.ServerIPv6} {
+		if raw == "" {
+			continue
+		}
+
+		ip, err := netip.ParseAddr(raw)
+		if err != nil {
+			logger.Warnf("Ignoring invalid server IP %q: %v", raw, err)
+			continue
+		}
+		ip, err = s.transformAddr(ip.Unmap())
+		if err != nil {
+			logger.Errorf("Not publishing server IP %s: %v", raw, err)
+			continue
+		}
+		if !s.publishesFamily(ip) || slices.Contains(addrs, ip) {
+			continue
+		}
+
+		// Detected IPs come from labels or node addresses, which may well hold
+		// an internal address that must not end up in public DNS
+		if !s.Config.AllowPrivateIP && !isPublicIP(ip) {
+			logger.Errorf("Refusing to publish non-public IP %s, set SENTINEL_ALLOW_PRIVATE_IP=true for internal DNS", ip)
+			continue
+		}
+		addrs = append(addrs, ip)
+	}
+
+	if len(addrs) == 0 && s.Config.IPFamily != IPFamilyDual && s.Config.IPFamily != "" {
+		logger.Warnf("No public %s address known for this node, nothing to publish", s.Config.IPFamily)
+	}
+	return addrs
+}
+
+// transformAddr returns the address to publish for ip, rewritten by
+// SENTINEL_IP_TRANSFORM if set
+func (s *Sentinel) transformAddr(ip netip.Addr) (netip.Addr, error) {
+	if s.ipTransform == nil {
+		return ip, nil
+	}
+
+	transformed, err := s.ipTransform.apply(ip)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if transformed != ip {
+		logger.Debugf("Publishing IP %s as %s", ip, transformed)
+	}
+	return transformed, nil
+}
+
+// publishesFamily reports whether addresses of the IP family of ip are published
+func (s *Sentinel) publishesFamily(ip netip.Addr) bool {
+	return (s.Config.IPFamily != IPFamilyIPv4 || ip.Is4()) && (s.Config.IPFamily != IPFamilyIPv6 || ip.Is6())
+}
+
+// desiredRecords returns the records called name should be reconciled to: a CNAME
+// to the configured target, or an A/AAAA record per server address
+func (s *Sentinel) desiredRecords(name string, ttl time.Duration) []libdns.Record {
+	if s.Config.RecordType == RecordTypeCNAME {
+		return []libdns.Record{
+			libdns.CNAME{
+				Name:   name,
+				TTL:    ttl,
+				Target: s.Config.CNAMETarget,
+			},
+		}
+	}
+
+	var records []libdns.Record
+	for _, ip := range s.serverAddrs() {
+		records = append(records, libdns.Address{
+			Name: name,
+			IP:   ip,
+			TTL:  ttl,
+		})
+	}
+	return records
+}
 
-	var currentIP string
+// sameContent compares record data as providers may return it: surrounding
+// whitespace is ignored, host names compare without trailing dot and case, and
+// addresses in their canonical form, e.g. 2001:DB8::1 equals 2001:db8:0::1
+func sameContent(rrType, a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+
+	switch rrType {
+	case RecordTypeCNAME:
+		return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+	case "A", "AAAA":
+		ipA, errA := netip.ParseAddr(a)
+		ipB, errB := netip.ParseAddr(b)
+		if errA == nil && errB == nil {
+			return ipA.Unmap() == ipB.Unmap()
+		}
+	}
+	return a == b
+}
+
+// decorate attaches the configured routing options to record if the provider of zone supports them
+func (s *Sentinel) decorate(zone string, record libdns.Record) libdns.Record {
+	decorator, ok := s.dnsClient(zone).(RecordDecorator)
+	if !ok || s.Config.RecordOptions.IsZero() {
+		return record
+	}
+	return decorator.DecorateRecord(record, s.Config.RecordOptions)
+}
+
+// updateRecord makes sure the record matching the name and type of desired has
+// the desired content and TTL
+func (s *Sentinel) updateRecord(ctx context.Context, zone string, records []libdns.Record, desired libdns.Record) recordResult {
+	want := desired.RR()
+	rrType := want.Type
+	name := want.Name
+	ttl := want.TTL
+
+	var current *libdns.RR
 	for _, record := range records {
 		rr := record.RR()
-		if rr.Name == s.Config.Record && rr.Type == "A" {
-			currentIP = rr.Data
+		if sameName(rr.Name, name, zone) && rr.Type == rrType {
+			current = &rr
 			break
 		}
 	}
 
-	if currentIP != s.Config.ServerIP {
-		log.Printf("DNS points to %s, should point to %s", currentIP, s.Config.ServerIP)
+	var currentData string
+	if current != nil {
+		currentData = current.Data
+	}
 
-		newRecords := []libdns.Record{
-			libdns.Address{
-				Name: s.Config.Record,
-				IP:   netip.MustParseAddr(s.Config.ServerIP),
-				TTL:  time.Duration(s.Config.RecordTTL) * time.Second,
-			},
-		}
+	var diffs []string
+	if !sameContent(rrType, currentData, want.Data) {
+		diffs = append(diffs, fmt.Sprintf("content %s -> %s", currentData, want.Data))
+	}
+	if current != nil && !s.ttlMatches(current.TTL, ttl) {
+		diffs = append(diffs, fmt.Sprintf("TTL %s -> %s", current.TTL, ttl))
+	}
 
-		_, err := s.DnsClient.SetRecords(ctx, zone, newRecords)
-		if err != nil {
-			log.Printf("DNS update failed: %v", err)
+	if len(diffs) == 0 && !s.forcing {
+		logger.Infof("DNS %s record %s correctly points to %s", rrType, name, want.Data)
+		return recordUnchanged
+	}
+
+	newRecords := []libdns.Record{s.decorate(zone, desired)}
+
+	// A missing record is created explicitly, as not every provider creates
+	// records through SetRecords reliably
+	operation := "set"
+	if current == nil {
+		operation = "create"
+		logger.Infof("DNS %s record %s does not exist, creating it", rrType, name)
+	} else if len(diffs) == 0 {
+		logger.Infof("DNS %s record %s already points to %s, rewriting it as requested", rrType, name, want.Data)
+	} else {
+		logger.Infof("DNS %s record %s differs: %s", rrType, name, strings.Join(diffs, ", "))
+	}
+
+	event := Event{Event: EventDNSUpdated, Record: libdns.AbsoluteName(name, zone), Old: currentData, New: want.Data}
+	if s.Config.DryRun {
+		logger.Infof("Dry run: would %s %s record %s with %s (TTL %s)", operation, rrType, name, want.Data, ttl)
+		event.Result = EventResultDryRun
+		s.emitEvent(event)
+		return recordDryRun
+	}
+
+	dnsUpdatesTotal.WithLabelValues("attempted").Inc()
+	var storedRecords []libdns.Record
+	err := s.withRetry(ctx, operation+" "+rrType+" record "+name, func(ctx context.Context) error {
+		defer observeDnsCall(operation+"_records", time.Now())
+		defer s.invalidateRecords(zone)
+
+		var err error
+		if current == nil {
+			spanCtx, span := s.providerSpan(ctx, "AppendRecords", zone, name)
+			storedRecords, err = s.dnsClient(zone).AppendRecords(spanCtx, zone, newRecords)
+			endSpan(span, err)
 		} else {
-			log.Printf("DNS update successful")
+			spanCtx, span := s.providerSpan(ctx, "SetRecords", zone, name)
+			storedRecords, err = s.dnsClient(zone).SetRecords(spanCtx, zone, newRecords)
+			endSpan(span, err)
 		}
-	} else {
-		log.Printf("DNS correctly points to %s", s.Config.ServerIP)
+		return err
+	})
+	if err != nil {
+		dnsUpdatesTotal.WithLabelValues("failed").Inc()
+		logger.Errorf("DNS %s record update failed: %v", rrType, s.reconcileError(operation, zone, name, err))
+		event.Result = EventResultFailed
+		s.emitEvent(event)
+		return recordFailed
+	}
+
+	s.rememberProviderTTL(ttl, storedRecords)
+
+	dnsUpdatesTotal.WithLabelValues("succeeded").Inc()
+	lastSuccessfulUpdateGauge.SetToCurrentTime()
+	logger.Infof("DNS %s %s for %s successful", rrType, operation, name)
+	event.Result = EventResultSucceeded
+	s.emitEvent(event)
+
+	s.notifyWebhook(WebhookEvent{
+		Event:  WebhookEventDNSUpdated,
+		Record: libdns.AbsoluteName(name, zone),
+		OldIP:  currentData,
+		NewIP:  want.Data,
+	})
+
+	if s.Config.VerifyTimeout > 0 {
+		go s.verifyPropagation(ctx, zone, rrType, libdns.AbsoluteName(name, zone), want.Data)
+	}
+	return recordUpdated
+}
+
+// ttlMatches compares the TTL returned by the provider with the configured one.
+// Providers may not report TTLs at all or clamp them to their own limits, so an
+// unreported TTL or the value the provider stored for our TTL last time counts as a match.
+func (s *Sentinel) ttlMatches(current, desired time.Duration) bool {
+	// A desired TTL of 0 leaves the TTL to the provider, any TTL is fine
+	if current == 0 || desired == 0 || current == desired {
+		return true
+	}
+
+	stored, ok := s.providerTTLs[desired]
+	return ok && stored == current
+}
+
+// rememberProviderTTL records the TTL the provider actually stored when we asked for desired
+func (s *Sentinel) rememberProviderTTL(desired time.Duration, records []libdns.Record) {
+	for _, record := range records {
+		stored := record.RR().TTL
+		if stored == 0 || desired == 0 || stored == desired {
+			continue
+		}
+
+		logger.Infof("DNS provider stored TTL %s instead of %s", stored, desired)
+		if s.providerTTLs == nil {
+			s.providerTTLs = make(map[time.Duration]time.Duration)
+		}
+		s.providerTTLs[desired] = stored
 	}
 }
 
-// Run starts the sentinel monitoring process
-func (s *Sentinel) Run() {
-	log.Printf("Sentinel DNS Monitor for %s.%s started", s.Config.Record, s.Config.Domain)
-	log.Printf("Server IP: %s", s.Config.ServerIP)
+// Run starts the sentinel monitoring process and blocks until ctx is cancelled.
+// In oneshot mode it returns after a single check with its result.
+func (s *Sentinel) Run(ctx context.Context) error {
+	for _, z := range s.zones() {
+		for _, fqdn := range z.fqdns() {
+			logger.Infof("Sentinel DNS Monitor for %s started", fqdn)
+		}
+	}
+	if s.Config.DryRun {
+		logger.Infof("Dry run enabled, DNS records will not be modified")
+	}
+	if s.Config.Mode == ModeCoordinator {
+		logger.Infof("Coordinating: records fall back to %s without a leader heartbeat for %s", s.Config.FallbackIP, s.Config.FallbackAfter)
+		_ = s.CheckAndUpdateDNS(ctx)
+		s.coordinatorLoop(ctx)
+		return nil
+	}
+	if s.Config.ForceLeader != ForceLeaderAuto {
+		logger.Warnf("Leadership is forced to %s by SENTINEL_FORCE_LEADER, the orchestrator is not asked", s.Config.ForceLeader)
+	}
+	if s.Config.RecordType == RecordTypeCNAME {
+		logger.Infof("CNAME target: %s", s.Config.CNAMETarget)
+	} else {
+		if s.Config.ServerIP != "" {
+			logger.Infof("Server IP: %s", s.Config.ServerIP)
+		} else {
+			logger.Infof("Server IP is detected from source %s when DNS is updated", s.Config.ipSourceNames())
+		}
+		if s.Config.ServerIPv6 != "" {
+			logger.Infof("Server IPv6: %s", s.Config.ServerIPv6)
+		}
+	}
 
-	configErrs := s.orchestration.GetConfigurationErrors()
-	if len(configErrs) > 0 {
-		log.Fatal("Invalid configuration: ", configErrs)
+	if configErrs := s.orchestration.GetConfigurationErrors(); len(configErrs) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(configErrs, "; "))
 	}
 
-	nodeName, _ := s.orchestration.GetNodeName()
-	log.Printf("Node name: %s", nodeName)
+	s.nodeName, _ = s.orchestration.GetNodeName()
+	logger.Infof("Node name: %s", s.nodeName)
 
 	// Initial check
-	s.CheckAndUpdateDNS()
+	err := s.CheckAndUpdateDNS(ctx)
+	if s.Config.Mode == ModeOneshot {
+		return err
+	}
+
+	// Periodically reconcile in case events were missed
+	if s.Config.ReconcileInterval > 0 {
+		go s.reconcileLoop(ctx)
+	}
+
+	// Cheaply detect out-of-band changes of the records between reconciles
+	if s.Config.CheckInterval > 0 {
+		go s.checkLoop(ctx)
+	}
+
+	// Poll leadership in case the event stream is unreliable
+	if s.Config.LeaderPollInterval > 0 {
+		go s.leaderPollLoop(ctx)
+	}
 
 	// Watch for events
-	s.orchestration.WatchEvents(s.CheckAndUpdateDNS)
+	s.orchestration.WatchEvents(ctx, func() { s.onEvent(ctx) })
+	return nil
+}
+
+// reconcileLoop runs CheckAndUpdateDNS every reconcile interval
+func (s *Sentinel) reconcileLoop(ctx context.Context) {
+	timer := time.NewTimer(s.jitter(s.Config.ReconcileInterval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			logger.Infof("Running periodic reconcile")
+			s.refreshRecords.Store(true)
+			_ = s.CheckAndUpdateDNS(ctx)
+			timer.Reset(s.jitter(s.Config.ReconcileInterval))
+		}
+	}
+}
+
+// jitter spreads interval randomly by up to TimerJitter percent in either
+// direction, so instances started together do not call the DNS provider in lockstep
+func (s *Sentinel) jitter(interval time.Duration) time.Duration {
+	spread := int64(interval) * s.Config.TimerJitter / 100
+	if spread <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int64N(2*spread+1)-spread)
+}
+
+// leaderPollLoop checks leadership every leader poll interval and runs
+// CheckAndUpdateDNS when it changed since the last check
+func (s *Sentinel) leaderPollLoop(ctx context.Context) {
+	timer := time.NewTimer(s.jitter(s.Config.LeaderPollInterval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			timer.Reset(s.jitter(s.Config.LeaderPollInterval))
+
+			isLeader, err := s.checkLeader(ctx)
+			if err != nil {
+				logger.Debugf("Leadership poll failed: %v", err)
+				continue
+			}
+
+			s.mu.Lock()
+			changed := isLeader != s.wasLeader
+			s.mu.Unlock()
+
+			if changed {
+				logger.Infof("Leadership poll detected a change, checking DNS")
+				_ = s.CheckAndUpdateDNS(ctx)
+			}
+		}
+	}
 }
 
 func getEnv(key, fallback string) string {
@@ -211,9 +1511,99 @@ func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(fullKey); exists {
 		return value
 	}
+	if value, exists := fileConfig[key]; exists {
+		return value
+	}
 	return fallback
 }
 
+// envName turns a record name into the suffix of its environment variables,
+// e.g. "www.app" becomes "www_app" and "@" becomes "APEX"
+func envName(record string) string {
+	if record == "@" || record == "" {
+		return "APEX"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' {
+			return '_'
+		}
+		return r
+	}, record)
+}
+
+// getEnvInt reads a non-negative integer from the environment, returning
+// fallback if the variable is unset or invalid
+func getEnvInt(key string, fallback int64) int64 {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < 0 {
+		logger.Warnf("invalid value %q for SENTINEL_%s, using default", raw, key)
+		return fallback
+	}
+	return value
+}
+
+// getEnvDuration reads a duration (e.g. "5m") from the environment, returning
+// fallback if the variable is unset or invalid
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil || value < 0 {
+		logger.Warnf("invalid duration %q for SENTINEL_%s, using default", raw, key)
+		return fallback
+	}
+	return value
+}
+
+// getEnvBool reads a boolean (true/false, 1/0, ...) from the environment,
+// returning fallback if the variable is unset or invalid
+func getEnvBool(key string, fallback bool) bool {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		logger.Warnf("invalid boolean %q for SENTINEL_%s, using default", raw, key)
+		return fallback
+	}
+	return value
+}
+
+// splitList splits a comma-separated value into its trimmed, non-empty parts
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getSecret reads a credential from the file named by SENTINEL_<key>_FILE, as
+// mounted by Docker and Kubernetes secrets, or else from SENTINEL_<key>
+func getSecret(key string) (string, error) {
+	if path := getEnv(key+"_FILE", ""); path != "" {
+		value, err := readSecret(path)
+		if err != nil {
+			return "", fmt.Errorf("could not read SENTINEL_%s_FILE: %v", key, err)
+		}
+		return value, nil
+	}
+	return getEnv(key, ""), nil
+}
+
 // readSecret reads a secret from the given path
 func readSecret(path string) (string, error) {
 	data, err := os.ReadFile(path)
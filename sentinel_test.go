@@ -0,0 +1,2587 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/libdns/powerdns"
+	"github.com/mittwald/go-powerdns/apis/zones"
+	"github.com/vultr/govultr/v3"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/net/dns/dnsmessage"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeDnsClient is an in-memory DnsClient recording the calls made to it
+type fakeDnsClient struct {
+	records  []libdns.Record
+	gets     int
+	appended []libdns.Record
+	set      []libdns.Record
+	deleted  []libdns.Record
+}
+
+func (f *fakeDnsClient) GetRecords(_ context.Context, _ string) ([]libdns.Record, error) {
+	f.gets++
+	return f.records, nil
+}
+
+func (f *fakeDnsClient) AppendRecords(_ context.Context, _ string, records []libdns.Record) ([]libdns.Record, error) {
+	f.appended = append(f.appended, records...)
+	return records, nil
+}
+
+func (f *fakeDnsClient) DeleteRecords(_ context.Context, _ string, records []libdns.Record) ([]libdns.Record, error) {
+	f.deleted = append(f.deleted, records...)
+	return records, nil
+}
+
+func (f *fakeDnsClient) SetRecords(_ context.Context, _ string, records []libdns.Record) ([]libdns.Record, error) {
+	f.set = append(f.set, records...)
+	return records, nil
+}
+
+// fakeOrchestration is an OrchestrationAdapter with a fixed leadership state and public IP
+type fakeOrchestration struct {
+	leader   bool
+	publicIP string
+	readyIPs []map[string]string
+	errs     []string
+}
+
+func (f *fakeOrchestration) GetConfigurationErrors() []string       { return f.errs }
+func (f *fakeOrchestration) GetNodeName() (string, error)           { return "node-1", nil }
+func (f *fakeOrchestration) GetNodePublicIP() (string, error)       { return f.publicIP, nil }
+func (f *fakeOrchestration) IsLeader(context.Context) (bool, error) { return f.leader, nil }
+func (f *fakeOrchestration) ListReadyNodeIPs(context.Context) ([]map[string]string, error) {
+	return f.readyIPs, nil
+}
+func (f *fakeOrchestration) WatchEvents(context.Context, func()) {}
+
+// newTestSentinel creates a Sentinel for lb.example.com using the given fakes
+func newTestSentinel(dnsClient DnsClient, orchestration OrchestrationAdapter) *Sentinel {
+	return &Sentinel{
+		Config: &Config{
+			Domain:    "example.com",
+			Records:   []string{"lb"},
+			RecordTTL: 300,
+		},
+		DnsClient:     dnsClient,
+		orchestration: orchestration,
+		ipSource:      &orchestratorIPSource{orchestration: orchestration},
+	}
+}
+
+func TestCheckAndUpdateDNS(t *testing.T) {
+	existing := []libdns.Record{
+		libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+	}
+
+	tests := []struct {
+		name         string
+		leader       bool
+		publicIP     string
+		records      []libdns.Record
+		wantSet      int
+		wantAppended int
+	}{
+		{"IP already matches", true, "1.2.3.4", existing, 0, 0},
+		{"IP differs", true, "5.6.7.8", existing, 1, 0},
+		{"record absent", true, "1.2.3.4", nil, 0, 1},
+		{"not leader", false, "5.6.7.8", existing, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dnsClient := &fakeDnsClient{records: tt.records}
+			sentinel := newTestSentinel(dnsClient, &fakeOrchestration{leader: tt.leader, publicIP: tt.publicIP})
+
+			if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+				t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+			}
+
+			if len(dnsClient.set) != tt.wantSet {
+				t.Errorf("expected %d SetRecords calls, got %v", tt.wantSet, dnsClient.set)
+			}
+			if len(dnsClient.appended) != tt.wantAppended {
+				t.Errorf("expected %d created records, got %v", tt.wantAppended, dnsClient.appended)
+			}
+			if !tt.leader && dnsClient.gets != 0 {
+				t.Errorf("expected no DNS lookups when not leader, got %d", dnsClient.gets)
+			}
+
+			for _, record := range append(dnsClient.set, dnsClient.appended...) {
+				if rr := record.RR(); rr.Data != tt.publicIP {
+					t.Errorf("expected record to point to %s, got %+v", tt.publicIP, rr)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateDNSCreatesMissingRecord(t *testing.T) {
+	dnsClient := &fakeDnsClient{}
+	sentinel := &Sentinel{
+		Config: &Config{
+			Domain:    "example.com",
+			Records:   []string{"lb"},
+			RecordTTL: 300,
+			ServerIP:  "1.2.3.4",
+		},
+		DnsClient: dnsClient,
+	}
+
+	if err := sentinel.updateDNS(context.Background()); err != nil {
+		t.Fatalf("updateDNS returned error: %v", err)
+	}
+
+	if len(dnsClient.set) != 0 {
+		t.Errorf("expected no SetRecords call, got %v", dnsClient.set)
+	}
+	if len(dnsClient.appended) != 1 {
+		t.Fatalf("expected one created record, got %v", dnsClient.appended)
+	}
+
+	rr := dnsClient.appended[0].RR()
+	if rr.Name != "lb" || rr.Type != "A" || rr.Data != "1.2.3.4" {
+		t.Errorf("unexpected record created: %+v", rr)
+	}
+}
+
+func TestSameName(t *testing.T) {
+	tests := []struct {
+		name     string
+		returned string
+		want     bool
+	}{
+		{"relative (INWX, Bunny)", "lb", true},
+		{"fully qualified", "lb.example.com", true},
+		{"fully qualified with trailing dot", "lb.example.com.", true},
+		{"different case", "LB.Example.COM.", true},
+		{"other record", "www", false},
+		{"other record with same suffix", "xlb.example.com", false},
+		{"same label in other zone", "lb.example.net.", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameName(tt.returned, "lb", "example.com."); got != tt.want {
+				t.Errorf("sameName(%q, %q) = %v, want %v", tt.returned, "lb", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSameNameProviders covers the names each provider returns for lb and the apex
+func TestSameNameProviders(t *testing.T) {
+	tests := []struct {
+		provider string
+		lb       string
+		apex     string
+	}{
+		{"inwx", "lb", "@"},
+		{"bunny", "lb", "@"},
+		{"gcloud", "lb", "@"},
+		{"fully qualified", "lb.example.com", "example.com"},
+		{"fully qualified with trailing dot", "lb.example.com.", "example.com."},
+		{"empty apex", "LB", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			if !sameName(tt.lb, "lb", "example.com") {
+				t.Errorf("expected %q to match lb", tt.lb)
+			}
+			if !sameName(tt.apex, "@", "example.com.") {
+				t.Errorf("expected %q to match the apex", tt.apex)
+			}
+			if sameName(tt.lb, "@", "example.com") || sameName(tt.apex, "lb", "example.com") {
+				t.Errorf("expected %q and %q not to match each other's name", tt.lb, tt.apex)
+			}
+		})
+	}
+}
+
+func TestUpdateDNSMatchesFullyQualifiedName(t *testing.T) {
+	dnsClient := &fakeDnsClient{
+		records: []libdns.Record{
+			libdns.RR{Name: "lb.example.com.", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+		},
+	}
+	sentinel := &Sentinel{
+		Config: &Config{
+			Domain:    "example.com",
+			Records:   []string{"lb"},
+			RecordTTL: 300,
+			ServerIP:  "1.2.3.4",
+		},
+		DnsClient: dnsClient,
+	}
+
+	if err := sentinel.updateDNS(context.Background()); err != nil {
+		t.Fatalf("updateDNS returned error: %v", err)
+	}
+
+	if len(dnsClient.set) != 0 || len(dnsClient.appended) != 0 {
+		t.Errorf("expected no changes, got set=%v appended=%v", dnsClient.set, dnsClient.appended)
+	}
+}
+
+func TestUpdateDNSManagesApex(t *testing.T) {
+	dnsClient := &fakeDnsClient{
+		records: []libdns.Record{
+			libdns.RR{Name: "example.com.", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+			libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+		},
+	}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{})
+	sentinel.Config.Records = []string{"@"}
+	sentinel.Config.ServerIP = "5.6.7.8"
+
+	if err := sentinel.updateDNS(context.Background()); err != nil {
+		t.Fatalf("updateDNS returned error: %v", err)
+	}
+
+	if len(dnsClient.appended) != 0 {
+		t.Errorf("expected the existing apex record to be updated, got created %v", dnsClient.appended)
+	}
+	if len(dnsClient.set) != 1 {
+		t.Fatalf("expected one updated record, got %v", dnsClient.set)
+	}
+	if rr := dnsClient.set[0].RR(); rr.Name != "@" || rr.Data != "5.6.7.8" {
+		t.Errorf("expected apex record pointing to 5.6.7.8, got %+v", rr)
+	}
+}
+
+func TestUpdateDNSUpdatesAllZones(t *testing.T) {
+	dnsClient := &fakeDnsClient{
+		records: []libdns.Record{
+			libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+		},
+	}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{})
+	sentinel.Config.ServerIP = "5.6.7.8"
+	sentinel.Config.ExtraZones = parseExtraZones("example.net; example.org=www", sentinel.Config.Records)
+
+	if err := sentinel.updateDNS(context.Background()); err != nil {
+		t.Fatalf("updateDNS returned error: %v", err)
+	}
+
+	if dnsClient.gets != 3 {
+		t.Errorf("expected the records of 3 zones to be read, got %d", dnsClient.gets)
+	}
+	if len(dnsClient.set) != 2 {
+		t.Errorf("expected lb to be updated in example.com and example.net, got %v", dnsClient.set)
+	}
+	if len(dnsClient.appended) != 1 || dnsClient.appended[0].RR().Name != "www" {
+		t.Errorf("expected www to be created in example.org, got %v", dnsClient.appended)
+	}
+}
+
+func TestNewSentinelReturnsProviderError(t *testing.T) {
+	_, err := NewSentinel(&Config{DnsProvider: "unknown", ServerIP: "1.2.3.4"})
+	if err == nil {
+		t.Fatal("expected error for unsupported DNS provider")
+	}
+}
+
+func TestNewSentinelWithStaticIP(t *testing.T) {
+	sentinel, err := NewSentinel(&Config{DnsProvider: DnsProviderNoop, ServerIP: "1.2.3.4"})
+	if err != nil {
+		t.Fatalf("NewSentinel returned error: %v", err)
+	}
+
+	if !sentinel.staticServerIP || !sentinel.initialized.Load() {
+		t.Errorf("expected an initialized sentinel with static IP, got %+v", sentinel)
+	}
+}
+
+func TestMultiModeKeepsOtherNodes(t *testing.T) {
+	dnsClient := &fakeDnsClient{
+		records: []libdns.Record{
+			libdns.RR{Name: "lb", Type: "A", Data: "9.9.9.9", TTL: 300 * time.Second},
+			libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+		},
+	}
+	orchestration := &fakeOrchestration{publicIP: "1.2.3.4"}
+	sentinel := newTestSentinel(dnsClient, orchestration)
+	sentinel.Config.Mode = ModeMulti
+
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+	if len(dnsClient.set) != 0 || len(dnsClient.appended) != 0 || len(dnsClient.deleted) != 0 {
+		t.Fatalf("expected no changes, got set=%v appended=%v deleted=%v", dnsClient.set, dnsClient.appended, dnsClient.deleted)
+	}
+
+	// After an IP change only the own old address is replaced
+	orchestration.publicIP = "5.6.7.8"
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+
+	if len(dnsClient.set) != 0 {
+		t.Errorf("expected no SetRecords call, got %v", dnsClient.set)
+	}
+	if len(dnsClient.appended) != 1 || dnsClient.appended[0].RR().Data != "5.6.7.8" {
+		t.Errorf("expected 5.6.7.8 to be added, got %v", dnsClient.appended)
+	}
+	if len(dnsClient.deleted) != 1 || dnsClient.deleted[0].RR().Data != "1.2.3.4" {
+		t.Errorf("expected 1.2.3.4 to be removed, got %v", dnsClient.deleted)
+	}
+}
+
+func TestUpdateDNSRespectsOwnership(t *testing.T) {
+	tests := []struct {
+		name        string
+		owner       string
+		wantUpdated bool
+		wantClaimed bool
+	}{
+		{"unowned", "", true, true},
+		{"owned by us", "heritage=sentinel,sentinel/owner=blue", true, false},
+		{"owned by other deployment", "heritage=sentinel,sentinel/owner=green", false, false},
+		{"owned by other tool", "heritage=external-dns,external-dns/owner=default", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := []libdns.Record{
+				libdns.RR{Name: "lb", Type: "A", Data: "5.6.7.8", TTL: 300 * time.Second},
+			}
+			if tt.owner != "" {
+				records = append(records, libdns.RR{Name: "_sentinel-owner.lb", Type: "TXT", Data: tt.owner})
+			}
+
+			dnsClient := &fakeDnsClient{records: records}
+			sentinel := newTestSentinel(dnsClient, &fakeOrchestration{leader: true, publicIP: "1.2.3.4"})
+			sentinel.Config.OwnerID = "blue"
+			sentinel.Config.ServerIP = "1.2.3.4"
+
+			err := sentinel.updateDNS(context.Background())
+			if tt.wantUpdated && err != nil {
+				t.Fatalf("updateDNS returned error: %v", err)
+			}
+
+			if updated := len(dnsClient.set) == 1; updated != tt.wantUpdated {
+				t.Errorf("expected update %v, got set=%v", tt.wantUpdated, dnsClient.set)
+			}
+			if claimed := len(dnsClient.appended) == 1; claimed != tt.wantClaimed {
+				t.Errorf("expected claim %v, got appended=%v", tt.wantClaimed, dnsClient.appended)
+			}
+		})
+	}
+}
+
+func TestRecordTTLOverride(t *testing.T) {
+	sentinel := newTestSentinel(&fakeDnsClient{}, &fakeOrchestration{})
+	sentinel.Config.Domain = "app.example.com"
+	sentinel.Config.Zone = "example.com"
+	sentinel.Config.Records = []string{"lb", "www"}
+	sentinel.Config.RecordTTLs = map[string]int64{"www": 3600}
+
+	if got := sentinel.recordTTL(sentinel.zones()[0], "www.app"); got != time.Hour {
+		t.Errorf("expected override for www, got %s", got)
+	}
+	if got := sentinel.recordTTL(sentinel.zones()[0], "lb.app"); got != 300*time.Second {
+		t.Errorf("expected global TTL for lb, got %s", got)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for record, want := range map[string]string{"lb": "lb", "www.app": "www_app", "my-lb": "my_lb", "@": "APEX"} {
+		if got := envName(record); got != want {
+			t.Errorf("envName(%q) = %q, want %q", record, got, want)
+		}
+	}
+}
+
+func TestRecordsCache(t *testing.T) {
+	dnsClient := &fakeDnsClient{
+		records: []libdns.Record{
+			libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+		},
+	}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{})
+	sentinel.Config.ServerIP = "1.2.3.4"
+	sentinel.Config.RecordsCacheTTL = time.Minute
+
+	for range 2 {
+		if err := sentinel.updateDNS(context.Background()); err != nil {
+			t.Fatalf("updateDNS returned error: %v", err)
+		}
+	}
+	if dnsClient.gets != 1 {
+		t.Errorf("expected the second check to use cached records, got %d lookups", dnsClient.gets)
+	}
+
+	// A write invalidates the cache
+	sentinel.Config.ServerIP = "5.6.7.8"
+	if err := sentinel.updateDNS(context.Background()); err != nil {
+		t.Fatalf("updateDNS returned error: %v", err)
+	}
+	if err := sentinel.updateDNS(context.Background()); err != nil {
+		t.Fatalf("updateDNS returned error: %v", err)
+	}
+	if dnsClient.gets != 2 {
+		t.Errorf("expected records to be read again after the update, got %d lookups", dnsClient.gets)
+	}
+
+	// A refresh request bypasses the cache
+	sentinel.refreshRecords.Store(true)
+	if err := sentinel.updateDNS(context.Background()); err != nil {
+		t.Fatalf("updateDNS returned error: %v", err)
+	}
+	if dnsClient.gets != 3 {
+		t.Errorf("expected a refresh to bypass the cache, got %d lookups", dnsClient.gets)
+	}
+}
+
+func TestRecordsCacheRefreshesAllZones(t *testing.T) {
+	primary := &fakeDnsClient{records: []libdns.Record{libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second}}}
+	other := &fakeDnsClient{records: []libdns.Record{libdns.RR{Name: "www", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second}}}
+	sentinel := newTestSentinel(primary, &fakeOrchestration{})
+	sentinel.Config.ServerIP = "1.2.3.4"
+	sentinel.Config.RecordsCacheTTL = time.Minute
+	sentinel.Config.ExtraZones = parseExtraZones("example.net=www", nil)
+	sentinel.zoneClients = map[string]DnsClient{"example.net.": other}
+
+	for range 2 {
+		if err := sentinel.updateDNS(context.Background()); err != nil {
+			t.Fatalf("updateDNS returned error: %v", err)
+		}
+	}
+
+	// The refresh of a periodic reconcile applies to every zone of the pass
+	sentinel.refreshRecords.Store(true)
+	if err := sentinel.updateDNS(context.Background()); err != nil {
+		t.Fatalf("updateDNS returned error: %v", err)
+	}
+	if primary.gets != 2 || other.gets != 2 {
+		t.Errorf("expected both zones to be read again, got %d and %d lookups", primary.gets, other.gets)
+	}
+}
+
+func TestUpdateDNSUsesProviderOfZone(t *testing.T) {
+	primary := &fakeDnsClient{}
+	other := &fakeDnsClient{}
+	sentinel := newTestSentinel(primary, &fakeOrchestration{})
+	sentinel.Config.ServerIP = "1.2.3.4"
+	sentinel.Config.ExtraZones = parseExtraZones("example.net:bunny=www", nil)
+	sentinel.zoneClients = map[string]DnsClient{"example.net.": other}
+
+	if sentinel.Config.ExtraZones[0].DnsProvider != DnsProviderBunny {
+		t.Fatalf("expected provider bunny for example.net, got %+v", sentinel.Config.ExtraZones[0])
+	}
+	if err := sentinel.updateDNS(context.Background()); err != nil {
+		t.Fatalf("updateDNS returned error: %v", err)
+	}
+
+	if len(primary.appended) != 1 || primary.appended[0].RR().Name != "lb" {
+		t.Errorf("expected lb to be created at the primary provider, got %v", primary.appended)
+	}
+	if len(other.appended) != 1 || other.appended[0].RR().Name != "www" {
+		t.Errorf("expected www to be created at the provider of example.net, got %v", other.appended)
+	}
+}
+
+func TestTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"attempt timeout", fmt.Errorf("get records: %w", context.DeadlineExceeded), true},
+		{"bunny server error", errors.New("Internal Server Error (500)"), true},
+		{"bunny rate limit", errors.New("Too Many Requests (429)"), true},
+		{"cloud dns unavailable", errors.New("googleapi: Error 503: The service is currently unavailable., backendError"), true},
+		{"powerdns unavailable", errors.New("unexpected status code 503: http://pdns:8081/api/v1/servers/localhost/zones Service Unavailable"), true},
+		{"inwx command failed", errors.New("(2400) Command failed"), true},
+		{"expired session", errors.New("(2002) Command use error. Reason: (1) session expired"), true},
+		{"bunny unauthorized", errors.New("Unauthorized (401)"), false},
+		{"inwx authentication error", errors.New("(2200) Authentication error"), false},
+		{"inwx object does not exist", errors.New("(2303) Object does not exist"), false},
+		{"bunny zone not found", errors.New("zone not found for domain: example.com."), false},
+		{"cancelled", context.Canceled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transientError(tt.err); got != tt.want {
+				t.Errorf("transientError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerAddrsIPFamily(t *testing.T) {
+	tests := []struct {
+		family string
+		want   []string
+	}{
+		{IPFamilyDual, []string{"1.2.3.4", "2001:db8::1"}},
+		{IPFamilyIPv4, []string{"1.2.3.4"}},
+		{IPFamilyIPv6, []string{"2001:db8::1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.family, func(t *testing.T) {
+			sentinel := newTestSentinel(&fakeDnsClient{}, &fakeOrchestration{})
+			sentinel.Config.ServerIP = "1.2.3.4"
+			sentinel.Config.ServerIPv6 = "2001:db8::1"
+			sentinel.Config.AllowPrivateIP = true
+			sentinel.Config.IPFamily = tt.family
+
+			var got []string
+			for _, addr := range sentinel.serverAddrs() {
+				got = append(got, addr.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected addresses %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAddIPByFamily(t *testing.T) {
+	ips := make(map[string]string)
+	for _, raw := range []string{"", "not-an-ip", "2001:db8::1", "::ffff:1.2.3.4", "5.6.7.8", "2001:db8::2"} {
+		addIPByFamily(ips, raw)
+	}
+
+	if ips[IPFamilyIPv4] != "1.2.3.4" || ips[IPFamilyIPv6] != "2001:db8::1" {
+		t.Errorf("expected the first address of each family, got %v", ips)
+	}
+}
+
+// fakeLabelReader serves node labels from a map
+type fakeLabelReader map[string]string
+
+func (f fakeLabelReader) GetCurrentNodeLabel(name string) (string, error) {
+	value, ok := f[name]
+	if !ok {
+		return "", fmt.Errorf("label %s not found", name)
+	}
+	return value, nil
+}
+
+func TestIPSources(t *testing.T) {
+	tests := []struct {
+		name   string
+		source IPSource
+		want   map[string]string
+	}{
+		{"static", &staticIPSource{ipv4: "203.0.113.1", ipv6: "2001:db8::1"}, map[string]string{IPFamilyIPv4: "203.0.113.1", IPFamilyIPv6: "2001:db8::1"}},
+		{"static ipv6 only", &staticIPSource{ipv6: "2001:db8::1"}, map[string]string{IPFamilyIPv6: "2001:db8::1"}},
+		{"labels", &labelIPSource{reader: fakeLabelReader{"public_ip": "203.0.113.2"}, labels: []string{"public_ip", "public_ipv6"}}, map[string]string{IPFamilyIPv4: "203.0.113.2"}},
+		{"orchestrator", &orchestratorIPSource{orchestration: &fakeOrchestration{publicIP: "203.0.113.3"}}, map[string]string{IPFamilyIPv4: "203.0.113.3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.source.PublicIPs()
+			if err != nil {
+				t.Fatalf("PublicIPs returned error: %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&labelIPSource{reader: fakeLabelReader{}, labels: []string{"public_ip"}}).PublicIPs(); err == nil {
+		t.Error("expected an error without labels")
+	}
+	if _, err := newIPSource(&Config{IPSource: IPSourceNodeExternalIP}, &fakeOrchestration{}); err == nil {
+		t.Error("expected an error for an adapter without node addresses")
+	}
+}
+
+// labelOrchestration is an adapter that can read node labels
+type labelOrchestration struct {
+	*fakeOrchestration
+	fakeLabelReader
+}
+
+func TestIPTransform(t *testing.T) {
+	transform, err := parseIPTransform("10.0.0.0/24=203.0.113.0/24, 10.0.1.5=198.51.100.7, fd00::/64=2001:db8:1::/64, 10.0.2.0/23=198.51.100.0/23")
+	if err != nil {
+		t.Fatalf("parseIPTransform returned error: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"10.0.0.7", "203.0.113.7"},
+		{"10.0.1.5", "198.51.100.7"},
+		{"fd00::42", "2001:db8:1::42"},
+		{"10.0.3.9", "198.51.101.9"},
+		{"192.0.2.1", "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		got, err := transform.apply(netip.MustParseAddr(tt.ip))
+		if err != nil {
+			t.Fatalf("apply(%s) returned error: %v", tt.ip, err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("apply(%s) = %s, want %s", tt.ip, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.1", "10.0.0.0/24=203.0.113.0/25", "10.0.0.0/24=2001:db8::/24", "exec:", "10.0.0.1=host"} {
+		if _, err := parseIPTransform(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+
+	// The transform runs before the public IP check, so NAT tables may map internal addresses
+	sentinel := newTestSentinel(&fakeDnsClient{}, &fakeOrchestration{})
+	sentinel.Config.ServerIP = "10.0.0.7"
+	sentinel.ipTransform = transform
+	if addrs := sentinel.serverAddrs(); len(addrs) != 1 || addrs[0].String() != "203.0.113.7" {
+		t.Errorf("serverAddrs = %v, want [203.0.113.7]", addrs)
+	}
+}
+
+func TestIPTransformExec(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "transform.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncase \"$1\" in 10.0.0.7) echo 203.0.113.70 ;; *) echo invalid ;; esac\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	transform, err := parseIPTransform("exec:" + script)
+	if err != nil {
+		t.Fatalf("parseIPTransform returned error: %v", err)
+	}
+	if got, err := transform.apply(netip.MustParseAddr("10.0.0.7")); err != nil || got.String() != "203.0.113.70" {
+		t.Errorf("apply = %s, %v, want 203.0.113.70", got, err)
+	}
+	if _, err := transform.apply(netip.MustParseAddr("10.0.0.8")); err == nil {
+		t.Error("expected an error for output that is not an IP")
+	}
+}
+
+func TestOrderedIPSource(t *testing.T) {
+	config := &Config{
+		IPSourceOrder: []string{IPSourceOrchestratorLabel, IPSourceOrchestrator, IPSourceStatic},
+		PublicIPLabel: "public_ip",
+		ServerIPv6:    "2001:db8::1",
+	}
+	orchestration := &fakeOrchestration{publicIP: "10.0.0.1"}
+	source, err := newIPSource(config, labelOrchestration{orchestration, fakeLabelReader{}})
+	if err != nil {
+		t.Fatalf("newIPSource returned error: %v", err)
+	}
+
+	// The label is missing and the orchestrator reports a private address
+	got, err := source.PublicIPs()
+	if err != nil {
+		t.Fatalf("PublicIPs returned error: %v", err)
+	}
+	if want := map[string]string{IPFamilyIPv6: "2001:db8::1"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	orchestration.publicIP = "203.0.113.3"
+	if got, _ := source.PublicIPs(); got[IPFamilyIPv4] != "203.0.113.3" {
+		t.Errorf("got %v, want the orchestrator address", got)
+	}
+
+	config.IPSourceOrder = []string{IPSourceOrchestratorLabel}
+	source, _ = newIPSource(config, labelOrchestration{orchestration, fakeLabelReader{}})
+	if _, err := source.PublicIPs(); err == nil {
+		t.Error("expected an error when no source yields a public IP")
+	}
+
+	config.IPSourceOrder = []string{IPSourceOrchestrator, "dhcp"}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), `"dhcp"`) {
+		t.Errorf("expected an error for an unknown IP source, got %v", err)
+	}
+}
+
+// dockerAPI serves canned Docker API responses by path
+type dockerAPI map[string]string
+
+func (d dockerAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	body, ok := d[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":"page not found: %s"}`, r.URL.Path)
+		return
+	}
+	fmt.Fprint(w, body)
+}
+
+// newTestDockerClient creates a DockerClient talking to a test server serving api
+func newTestDockerClient(t *testing.T, api dockerAPI) *DockerClient {
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	config := &Config{
+		PublicIPLabel:   "public_ip",
+		DockerIPSources: []string{DockerIPSourceLabel, DockerIPSourceAddr},
+	}
+	return NewDockerClientWithTransport(config, server.Client().Transport, server.URL)
+}
+
+// dockerNodes is a swarm of a leading manager, a second manager and a worker
+const dockerNodes = `[
+	{"ID":"node-1","ManagerStatus":{"Leader":true},"Description":{"Hostname":"manager-1"},
+	 "Spec":{"Labels":{"public_ip":"203.0.113.1"},"Availability":"active"},"Status":{"State":"ready","Addr":"10.0.0.1"}},
+	{"ID":"node-2","ManagerStatus":{"Leader":false},"Description":{"Hostname":"manager-2"},
+	 "Spec":{"Labels":{},"Availability":"active"},"Status":{"State":"ready","Addr":"10.0.0.2"}},
+	{"ID":"node-3","Description":{"Hostname":"worker-1"},
+	 "Spec":{"Labels":{"public_ip":"203.0.113.3"},"Availability":"drain"},"Status":{"State":"ready","Addr":"10.0.0.3"}}
+]`
+
+func TestDockerSwarmActive(t *testing.T) {
+	active := newTestDockerClient(t, dockerAPI{"/swarm": `{"ID":"swarm-1"}`})
+	if !active.IsSwarmActive() {
+		t.Error("expected an active swarm")
+	}
+
+	inactive := newTestDockerClient(t, dockerAPI{"/swarm": `{"message":"This node is not a swarm manager."}`})
+	if inactive.IsSwarmActive() {
+		t.Error("expected no active swarm")
+	}
+
+	invalid := newTestDockerClient(t, dockerAPI{"/swarm": `not json`})
+	if invalid.IsSwarmActive() {
+		t.Error("expected no active swarm for an invalid response")
+	}
+}
+
+func TestDockerIsLeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		nodeID  string
+		want    bool
+		wantErr bool
+	}{
+		{"leader", "node-1", true, false},
+		{"manager", "node-2", false, false},
+		{"worker", "node-3", false, false},
+		{"no node ID", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDockerClient(t, dockerAPI{
+				"/info":  fmt.Sprintf(`{"Swarm":{"NodeID":%q}}`, tt.nodeID),
+				"/nodes": dockerNodes,
+			})
+
+			got, err := d.IsLeader(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsLeader returned error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsLeader = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDockerLeaderNotAvailable(t *testing.T) {
+	d := newTestDockerClient(t, dockerAPI{
+		"/info": `{"Swarm":{"NodeID":"node-1"}}`,
+		"/nodes": `[{"ID":"node-1","ManagerStatus":{"Leader":true},
+			"Spec":{"Availability":"drain"},"Status":{"State":"ready"}}]`,
+	})
+
+	if leader, err := d.IsLeader(context.Background()); err != nil || leader {
+		t.Errorf("expected a drained leader not to lead, got %v, %v", leader, err)
+	}
+}
+
+func TestNomadRequestsFollowContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	t.Setenv("NOMAD_ADDR", server.URL)
+	n := NewNomadClient(&Config{PublicIPLabel: "public_ip"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := n.IsLeader(ctx); err == nil {
+		t.Error("expected an error for a cancelled request")
+	}
+	if _, err := n.ListReadyNodeIPs(ctx); err == nil {
+		t.Error("expected an error for a cancelled request")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("requests took %s after the context was cancelled", elapsed)
+	}
+}
+
+func TestDockerConfigurationErrors(t *testing.T) {
+	for _, tt := range []struct {
+		nodeID    string
+		labelOnly bool
+		wantErr   bool
+	}{
+		{"node-1", false, false}, // labelled leader
+		{"node-2", false, false}, // unlabelled manager, only warned about
+		{"node-4", false, true},  // unlabelled leader
+		{"node-5", false, false}, // unlabelled leader with a swarm address
+		{"node-5", true, true},   // the swarm address does not count for orchestrator-label
+	} {
+		d := newTestDockerClient(t, dockerAPI{
+			"/_ping":        "OK",
+			"/swarm":        `{"ID":"swarm-1"}`,
+			"/info":         fmt.Sprintf(`{"Swarm":{"NodeID":%q}}`, tt.nodeID),
+			"/nodes/node-1": `{"ID":"node-1","ManagerStatus":{"Leader":true},"Spec":{"Labels":{"public_ip":"203.0.113.1"}}}`,
+			"/nodes/node-2": `{"ID":"node-2","ManagerStatus":{"Leader":false},"Spec":{"Labels":{}}}`,
+			"/nodes/node-4": `{"ID":"node-4","ManagerStatus":{"Leader":true},"Spec":{"Labels":{}}}`,
+			"/nodes/node-5": `{"ID":"node-5","ManagerStatus":{"Leader":true},"Spec":{"Labels":{}},"Status":{"Addr":"203.0.113.5"}}`,
+		})
+		d.ipSources = []string{DockerIPSourceLabel, DockerIPSourceAddr}
+		d.requirePublicIP = true
+		d.labelOnly = tt.labelOnly
+
+		if errs := d.GetConfigurationErrors(); (len(errs) > 0) != tt.wantErr {
+			t.Errorf("%s: got configuration errors %v, want errors %v", tt.nodeID, errs, tt.wantErr)
+		}
+	}
+}
+
+func TestDockerRequirePublicIP(t *testing.T) {
+	tests := []struct {
+		source string
+		order  []string
+		want   bool
+	}{
+		{IPSourceOrchestrator, nil, true},
+		{IPSourceExternal, nil, false},
+		{IPSourceOrchestrator, []string{IPSourceOrchestrator}, true},
+		{IPSourceOrchestrator, []string{IPSourceExternal, IPSourceOrchestrator}, false},
+		{IPSourceOrchestrator, []string{IPSourceOrchestratorLabel}, true},
+		{IPSourceOrchestrator, []string{IPSourceOrchestratorLabel, IPSourceOrchestrator}, true},
+		{IPSourceOrchestrator, []string{IPSourceOrchestratorLabel, IPSourceExternal}, false},
+	}
+
+	for _, tt := range tests {
+		config := &Config{RecordType: RecordTypeAddress, IPSource: tt.source, IPSourceOrder: tt.order}
+		d := NewDockerClientWithTransport(config, http.DefaultTransport, "http://docker")
+		if d.requirePublicIP != tt.want {
+			t.Errorf("source %s, order %v: requirePublicIP = %v, want %v", tt.source, tt.order, d.requirePublicIP, tt.want)
+		}
+	}
+}
+
+func TestRunConfigurationErrors(t *testing.T) {
+	dnsClient := &fakeDnsClient{}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{errs: []string{"Docker API not reachable"}})
+
+	// The error is returned for main to shut down gracefully
+	if err := sentinel.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "Docker API not reachable") {
+		t.Errorf("expected Run to return the configuration error, got %v", err)
+	}
+	if dnsClient.gets != 0 {
+		t.Error("expected no DNS access with an invalid configuration")
+	}
+}
+
+func TestDockerListReadyNodeIPs(t *testing.T) {
+	d := newTestDockerClient(t, dockerAPI{"/nodes": dockerNodes})
+
+	ips, err := d.ListReadyNodeIPs(context.Background())
+	if err != nil {
+		t.Fatalf("ListReadyNodeIPs returned error: %v", err)
+	}
+	// The drained worker is skipped, manager-2 falls back to its swarm address
+	want := []map[string]string{{IPFamilyIPv4: "203.0.113.1"}, {IPFamilyIPv4: "10.0.0.2"}}
+	if !slices.EqualFunc(ips, want, maps.Equal) {
+		t.Errorf("got %v, want %v", ips, want)
+	}
+
+	failing := newTestDockerClient(t, dockerAPI{})
+	if _, err := failing.ListReadyNodeIPs(context.Background()); err == nil {
+		t.Error("expected an error for a failed node list")
+	}
+}
+
+func TestDockerNodeLabels(t *testing.T) {
+	d := newTestDockerClient(t, dockerAPI{
+		"/info":         `{"Swarm":{"NodeID":"node-1"}}`,
+		"/nodes/node-1": `{"ID":"node-1","Description":{"Hostname":"manager-1"},"Spec":{"Labels":{"public_ip":"203.0.113.1"}}}`,
+	})
+
+	if id, err := d.GetCurrentNodeID(); err != nil || id != "node-1" {
+		t.Errorf("GetCurrentNodeID = %q, %v, want node-1", id, err)
+	}
+	if name, err := d.GetNodeName(); err != nil || name != "manager-1" {
+		t.Errorf("GetNodeName = %q, %v, want manager-1", name, err)
+	}
+	if ip, err := d.GetCurrentNodeLabel("public_ip"); err != nil || ip != "203.0.113.1" {
+		t.Errorf("GetCurrentNodeLabel = %q, %v, want 203.0.113.1", ip, err)
+	}
+	if ip, err := d.GetNodePublicIP(); err != nil || ip != "203.0.113.1" {
+		t.Errorf("GetNodePublicIP = %q, %v, want 203.0.113.1", ip, err)
+	}
+
+	if _, err := d.GetCurrentNodeLabel("missing"); err == nil {
+		t.Error("expected an error for a missing label")
+	}
+	if _, err := d.GetNodeLabel("node-404", "public_ip"); err == nil {
+		t.Error("expected an error for an unknown node")
+	}
+}
+
+// newTestK8sClient creates a K8sClient for node-1 backed by a fake clientset holding objects
+func newTestK8sClient(t *testing.T, objects ...runtime.Object) *K8sClient {
+	t.Setenv("NODE_NAME", "node-1")
+	config := &Config{
+		K8sLeaderMode:     K8sLeaderModeControllerManager,
+		K8sLeaseName:      DefaultK8sControllerManagerLeaseName,
+		K8sLeaseNamespace: DefaultK8sControllerManagerLeaseNamespace,
+		PublicIPLabel:     "public_ip",
+	}
+	return NewK8sClientWithClientset(config, fake.NewSimpleClientset(objects...))
+}
+
+// controllerManagerLease returns the kube-controller-manager lease held by holder
+func controllerManagerLease(holder *string) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DefaultK8sControllerManagerLeaseName,
+			Namespace: DefaultK8sControllerManagerLeaseNamespace,
+		},
+		Spec: coordinationv1.LeaseSpec{HolderIdentity: holder},
+	}
+}
+
+func TestK8sIsLeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		holder *string
+		want   bool
+	}{
+		{"holder is node", ptr("node-1_3f2a9c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b"), true},
+		{"other node", ptr("node-2_3f2a9c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b"), false},
+		{"node name prefix", ptr("node-10_3f2a9c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b"), false},
+		{"holder FQDN", ptr("node-1.cluster.local_3f2a9c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b"), true},
+		{"no holder", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newTestK8sClient(t, controllerManagerLease(tt.holder))
+
+			got, err := k.IsLeader(context.Background())
+			if err != nil {
+				t.Fatalf("IsLeader returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsLeader = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := newTestK8sClient(t).IsLeader(context.Background()); err == nil {
+		t.Error("expected an error without lease")
+	}
+}
+
+func TestK8sInformerCache(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+	clientset := fake.NewSimpleClientset(
+		controllerManagerLease(ptr("node-1_3f2a9c1e")),
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"public_ip": "203.0.113.1"}}},
+	)
+	k := NewK8sClientWithClientset(&Config{
+		K8sLeaderMode:     K8sLeaderModeControllerManager,
+		K8sLeaseName:      DefaultK8sControllerManagerLeaseName,
+		K8sLeaseNamespace: DefaultK8sControllerManagerLeaseNamespace,
+		PublicIPLabel:     "public_ip",
+	}, clientset)
+	defer k.stopInformers()
+
+	for range 3 {
+		if leader, err := k.IsLeader(context.Background()); err != nil || !leader {
+			t.Fatalf("IsLeader = %v, %v, want true", leader, err)
+		}
+		if ip, err := k.GetNodePublicIP(); err != nil || ip != "203.0.113.1" {
+			t.Fatalf("GetNodePublicIP = %q, %v, want 203.0.113.1", ip, err)
+		}
+	}
+
+	// Only the informers talk to the API server
+	for _, action := range clientset.Actions() {
+		if verb := action.GetVerb(); verb != "list" && verb != "watch" {
+			t.Errorf("unexpected %s %s request", verb, action.GetResource().Resource)
+		}
+	}
+}
+
+func TestK8sNodePublicIP(t *testing.T) {
+	externalIP := v1.NodeStatus{Addresses: []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeExternalIP, Address: "203.0.113.2"},
+	}}
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		status  v1.NodeStatus
+		want    string
+		wantErr bool
+	}{
+		{"label before ExternalIP", map[string]string{"public_ip": "203.0.113.1"}, externalIP, "203.0.113.1", false},
+		{"ExternalIP without label", nil, externalIP, "203.0.113.2", false},
+		{"no public IP", nil, v1.NodeStatus{Addresses: externalIP.Addresses[:1]}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newTestK8sClient(t, &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: tt.labels},
+				Status:     tt.status,
+			})
+
+			got, err := k.GetNodePublicIP()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetNodePublicIP returned error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetNodePublicIP = %q, want %q", got, tt.want)
+			}
+
+			ips, err := k.GetNodePublicIPs()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetNodePublicIPs returned error %v, want error %v", err, tt.wantErr)
+			}
+			if ips[IPFamilyIPv4] != tt.want {
+				t.Errorf("GetNodePublicIPs = %v, want %s", ips, tt.want)
+			}
+		})
+	}
+}
+
+func TestHolderMatchesNode(t *testing.T) {
+	tests := []struct {
+		holder string
+		node   string
+		want   bool
+	}{
+		{"node-1_3f2a9c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b", "node-1", true},
+		{"node-10_3f2a9c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b", "node-1", false},
+		{"node-1_3f2a9c1e-5b7d-4e8f-9a0b-1c2d3e4f5a6b", "node-10", false},
+		{"node-1.example.com_3f2a9c1e", "node-1", true},
+		{"node-1_3f2a9c1e", "node-1.example.com", true},
+		{"node-1.example.com_3f2a9c1e", "node-1.example.com", true},
+		{"Node-1.Example.com._3f2a9c1e", "node-1.example.com", true},
+		{"node-1.example.com_3f2a9c1e", "node-1.example.org", false},
+		{"node-1.example.com_3f2a9c1e", "example.com", false},
+		{"node-1", "node-1", true},
+		{"_3f2a9c1e", "node-1", false},
+		{"node-1_3f2a9c1e", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := holderMatchesNode(tt.holder, tt.node); got != tt.want {
+			t.Errorf("holderMatchesNode(%q, %q) = %v, want %v", tt.holder, tt.node, got, tt.want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestSameContent(t *testing.T) {
+	tests := []struct {
+		rrType string
+		a, b   string
+		want   bool
+	}{
+		{"A", "1.2.3.4", "1.2.3.4", true},
+		{"A", " 1.2.3.4\n", "1.2.3.4", true},
+		{"A", "::ffff:1.2.3.4", "1.2.3.4", true},
+		{"A", "1.2.3.4", "1.2.3.5", false},
+		{"AAAA", "2001:DB8::1", "2001:db8:0:0::1", true},
+		{"AAAA", "2001:db8::1", "2001:db8::2", false},
+		{"A", "", "1.2.3.4", false},
+		{RecordTypeCNAME, "LB.Example.com.", "lb.example.com", true},
+		{RecordTypeCNAME, " lb.example.com ", "lb.example.com.", true},
+		{RecordTypeCNAME, "lb.example.com", "lb.example.org", false},
+	}
+
+	for _, tt := range tests {
+		if got := sameContent(tt.rrType, tt.a, tt.b); got != tt.want {
+			t.Errorf("sameContent(%s, %q, %q) = %v, want %v", tt.rrType, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateDNSIgnoresContentFormatting(t *testing.T) {
+	dnsClient := &fakeDnsClient{
+		records: []libdns.Record{
+			libdns.RR{Name: "lb", Type: "A", Data: " 1.2.3.4 ", TTL: 300 * time.Second},
+		},
+	}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{leader: true, publicIP: "1.2.3.4"})
+
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+	if len(dnsClient.set) != 0 || len(dnsClient.appended) != 0 {
+		t.Errorf("expected no changes, got set=%v appended=%v", dnsClient.set, dnsClient.appended)
+	}
+}
+
+func TestUpdateDNSSkipsWhenLocked(t *testing.T) {
+	lockFile := t.TempDir() + "/sentinel.lock"
+	unlock, err := acquireFileLock(context.Background(), lockFile, time.Second)
+	if err != nil {
+		t.Fatalf("acquireFileLock returned error: %v", err)
+	}
+
+	dnsClient := &fakeDnsClient{}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{leader: true, publicIP: "1.2.3.4"})
+	sentinel.Config.LockFile = lockFile
+	sentinel.Config.LockTimeout = 200 * time.Millisecond
+
+	// The lock is held, so the reconcile is skipped
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+	if len(dnsClient.appended) != 0 {
+		t.Fatalf("expected no changes while locked, got %v", dnsClient.appended)
+	}
+
+	unlock()
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+	if len(dnsClient.appended) != 1 {
+		t.Errorf("expected the record to be created after unlocking, got %v", dnsClient.appended)
+	}
+}
+
+func TestUnknownPublicIP(t *testing.T) {
+	dnsClient := &fakeDnsClient{}
+	orchestration := &fakeOrchestration{}
+	sentinel := newTestSentinel(dnsClient, orchestration)
+
+	// Followers never need their IP
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error for a follower: %v", err)
+	}
+
+	orchestration.leader = true
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err == nil {
+		t.Fatal("expected an error for a leader without public IP")
+	}
+	if len(dnsClient.set) != 0 || len(dnsClient.appended) != 0 || len(dnsClient.deleted) != 0 {
+		t.Errorf("expected no changes, got set=%v appended=%v deleted=%v", dnsClient.set, dnsClient.appended, dnsClient.deleted)
+	}
+
+	orchestration.publicIP = "1.2.3.4"
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+	if len(dnsClient.appended) != 1 {
+		t.Errorf("expected the record to be created once the IP is known, got %v", dnsClient.appended)
+	}
+}
+
+// fakePowerDNS is a PowerDNS API serving a single zone from memory
+type fakePowerDNS struct {
+	zone   string
+	rrsets []zones.ResourceRecordSet
+}
+
+func (f *fakePowerDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("X-API-Key") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"Unauthorized"}`)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/api/v1/servers/localhost/zones":
+		list := []zones.Zone{}
+		if r.URL.Query().Get("zone") == f.zone {
+			list = append(list, zones.Zone{ID: f.zone, Name: f.zone})
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	case r.URL.Path != "/api/v1/servers/localhost/zones/"+f.zone:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"Not Found"}`)
+	case r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(zones.Zone{ID: f.zone, Name: f.zone, ResourceRecordSets: f.rrsets})
+	case r.Method == http.MethodPatch:
+		var body zones.Zone
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, change := range body.ResourceRecordSets {
+			f.rrsets = slices.DeleteFunc(f.rrsets, func(rrset zones.ResourceRecordSet) bool {
+				return rrset.Name == change.Name && rrset.Type == change.Type
+			})
+			// Replacing an rrset with no records deletes it
+			if change.ChangeType == zones.ChangeTypeReplace && len(change.Records) > 0 {
+				change.ChangeType = 0
+				f.rrsets = append(f.rrsets, change)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestPowerDnsClient(t *testing.T) {
+	api := &fakePowerDNS{zone: "example.com."}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	t.Setenv("SENTINEL_POWERDNS_SERVER_URL", server.URL+"/")
+	t.Setenv("SENTINEL_POWERDNS_API_TOKEN", "secret")
+	client, err := configurePowerDNS(&Config{})
+	if err != nil {
+		t.Fatalf("configurePowerDNS returned error: %v", err)
+	}
+
+	ctx := context.Background()
+	record := func(ip string) libdns.Record {
+		return libdns.Address{Name: "lb", IP: netip.MustParseAddr(ip), TTL: 60 * time.Second}
+	}
+
+	if _, err := client.AppendRecords(ctx, "example.com.", []libdns.Record{record("1.2.3.4")}); err != nil {
+		t.Fatalf("AppendRecords returned error: %v", err)
+	}
+	if _, err := client.AppendRecords(ctx, "example.com.", []libdns.Record{record("5.6.7.8")}); err != nil {
+		t.Fatalf("AppendRecords returned error: %v", err)
+	}
+	if _, err := client.DeleteRecords(ctx, "example.com.", []libdns.Record{record("1.2.3.4")}); err != nil {
+		t.Fatalf("DeleteRecords returned error: %v", err)
+	}
+
+	records, err := client.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Fatalf("GetRecords returned error: %v", err)
+	}
+	if len(records) != 1 || records[0].RR().Name != "lb" || records[0].RR().Data != "5.6.7.8" || records[0].RR().TTL != 60*time.Second {
+		t.Fatalf("expected lb A 5.6.7.8, got %v", records)
+	}
+
+	if _, err := client.SetRecords(ctx, "example.com.", []libdns.Record{record("9.9.9.9")}); err != nil {
+		t.Fatalf("SetRecords returned error: %v", err)
+	}
+	if _, err := client.DeleteRecords(ctx, "example.com.", []libdns.Record{record("9.9.9.9")}); err != nil {
+		t.Fatalf("DeleteRecords returned error: %v", err)
+	}
+	if len(api.rrsets) != 0 {
+		t.Errorf("expected the empty rrset to be deleted, got %v", api.rrsets)
+	}
+
+	if _, err := client.GetRecords(ctx, "example.org."); !errors.Is(err, ErrZoneNotFound) {
+		t.Errorf("expected ErrZoneNotFound for an unknown zone, got %v", err)
+	}
+
+	wrong := &PowerDnsClient{Provider: &powerdns.Provider{ServerURL: server.URL, APIToken: "wrong"}}
+	_, err = wrong.GetRecords(ctx, "example.com.")
+	if code, category := providerErrorCode(DnsProviderPowerDNS, err); code != "401" || category != ProviderErrorAuth {
+		t.Errorf("expected an auth error for a wrong API token, got %q %q from %v", code, category, err)
+	}
+}
+
+// fakeDesecRRSet is a resource record set of the deSEC API, the apex has an empty subname
+type fakeDesecRRSet struct {
+	Subname string   `json:"subname"`
+	Type    string   `json:"type"`
+	TTL     int64    `json:"ttl,omitempty"`
+	Records []string `json:"records"`
+}
+
+// fakeDesec is a deSEC API serving a single domain from memory
+type fakeDesec struct {
+	rrsets []fakeDesecRRSet
+}
+
+func (f *fakeDesec) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Token secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"detail":"Invalid token."}`)
+		return
+	}
+	path, found := strings.CutPrefix(r.URL.Path, "/api/v1/domains/example.com/rrsets/")
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"detail":"Not found."}`)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		_ = json.NewEncoder(w).Encode(f.rrsets)
+	case r.Method == http.MethodGet:
+		subname, rrType, _ := strings.Cut(strings.Trim(path, "/"), "/")
+		for _, rrset := range f.rrsets {
+			if (rrset.Subname == subname || rrset.Subname == "" && subname == "@") && rrset.Type == rrType {
+				_ = json.NewEncoder(w).Encode(rrset)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"detail":"Not found."}`)
+	case r.Method == http.MethodPut:
+		var changes []fakeDesecRRSet
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, change := range changes {
+			if change.TTL != 0 && change.TTL < desecMinTTL {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"ttl":["Ensure this value is greater than or equal to 3600."]}`)
+				return
+			}
+			f.rrsets = slices.DeleteFunc(f.rrsets, func(rrset fakeDesecRRSet) bool {
+				return rrset.Subname == change.Subname && rrset.Type == change.Type
+			})
+			if len(change.Records) > 0 {
+				f.rrsets = append(f.rrsets, change)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(changes)
+	}
+}
+
+// redirectTransport sends all requests to the server at target
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestDesecDnsClient(t *testing.T) {
+	api := &fakeDesec{rrsets: []fakeDesecRRSet{
+		{Subname: "", Type: "NS", TTL: 3600, Records: []string{"ns1.desec.io."}},
+		{Subname: "www", Type: "CNAME", TTL: 3600, Records: []string{"example.com."}},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	// The provider talks to the fixed deSEC URL through the default client
+	target, _ := url.Parse(server.URL)
+	http.DefaultClient.Transport = redirectTransport{target: target}
+	defer func() { http.DefaultClient.Transport = nil }()
+
+	t.Setenv("SENTINEL_DESEC_TOKEN", "secret")
+	client, err := configureDesec(&Config{})
+	if err != nil {
+		t.Fatalf("configureDesec returned error: %v", err)
+	}
+
+	ctx := context.Background()
+	record := func(name, ip string) libdns.Record {
+		return libdns.Address{Name: name, IP: netip.MustParseAddr(ip), TTL: 60 * time.Second}
+	}
+
+	// The TTL is raised to the deSEC minimum
+	stored, err := client.AppendRecords(ctx, "example.com.", []libdns.Record{record("@", "1.2.3.4")})
+	if err != nil {
+		t.Fatalf("AppendRecords returned error: %v", err)
+	}
+	if len(stored) != 1 || stored[0].RR().TTL != desecMinTTL*time.Second {
+		t.Errorf("expected the stored TTL to be %ds, got %v", desecMinTTL, stored)
+	}
+	if _, err := client.AppendRecords(ctx, "example.com.", []libdns.Record{record("@", "5.6.7.8")}); err != nil {
+		t.Fatalf("AppendRecords returned error: %v", err)
+	}
+	if _, err := client.DeleteRecords(ctx, "example.com.", []libdns.Record{record("@", "1.2.3.4")}); err != nil {
+		t.Fatalf("DeleteRecords returned error: %v", err)
+	}
+
+	// The apex is named "@"
+	records, err := client.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Fatalf("GetRecords returned error: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %v", records)
+	}
+	if rr := records[2].RR(); rr.Name != "@" || rr.Type != "A" || rr.Data != "5.6.7.8" {
+		t.Errorf("expected @ A 5.6.7.8, got %v", rr)
+	}
+
+	if _, err := client.DeleteRecords(ctx, "example.com.", []libdns.Record{record("@", "5.6.7.8")}); err != nil {
+		t.Fatalf("DeleteRecords returned error: %v", err)
+	}
+	if len(api.rrsets) != 2 {
+		t.Errorf("expected the empty rrset to be deleted, got %v", api.rrsets)
+	}
+
+	if _, err := client.GetRecords(ctx, "example.org."); !errors.Is(err, ErrZoneNotFound) {
+		t.Errorf("expected ErrZoneNotFound for an unknown domain, got %v", err)
+	}
+	client.Token = "wrong"
+	_, err = client.GetRecords(ctx, "example.com.")
+	if code, category := providerErrorCode(DnsProviderDesec, err); code != "401" || category != ProviderErrorAuth {
+		t.Errorf("expected an auth error for a wrong token, got %q %q from %v", code, category, err)
+	}
+}
+
+// fakeNamecheapHost is a host record of the Namecheap XML API
+type fakeNamecheapHost struct {
+	Name    string
+	Type    string
+	Address string
+	TTL     int64
+}
+
+// fakeNamecheap is a Namecheap XML API serving the domain example.com from
+// memory, accepting requests from allowedIP only
+type fakeNamecheap struct {
+	allowedIP string
+	hosts     []fakeNamecheapHost
+}
+
+func (f *fakeNamecheap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	if r.Form.Get("ClientIp") != f.allowedIP {
+		fmt.Fprint(w, `<ApiResponse Status="ERROR"><Errors><Error Number="1011150">Invalid request IP</Error></Errors></ApiResponse>`)
+		return
+	}
+	if r.Form.Get("Command") == "namecheap.domains.getTldList" {
+		fmt.Fprint(w, `<ApiResponse Status="OK"><Errors/><CommandResponse><Tlds><Tld Name="com"/><Tld Name="org"/></Tlds></CommandResponse></ApiResponse>`)
+		return
+	}
+	if r.Form.Get("SLD") != "example" || r.Form.Get("TLD") != "com" {
+		fmt.Fprint(w, `<ApiResponse Status="ERROR"><Errors><Error Number="2019166">Domain not found</Error></Errors></ApiResponse>`)
+		return
+	}
+
+	switch r.Form.Get("Command") {
+	case "namecheap.domains.dns.getHosts":
+		fmt.Fprint(w, `<ApiResponse Status="OK"><Errors/><CommandResponse><DomainDNSGetHostsResult Domain="example.com" EmailType="MX">`)
+		for _, host := range f.hosts {
+			fmt.Fprintf(w, `<host Name="%s" Type="%s" Address="%s" TTL="%d"/>`, host.Name, host.Type, host.Address, host.TTL)
+		}
+		fmt.Fprint(w, `</DomainDNSGetHostsResult></CommandResponse></ApiResponse>`)
+	case "namecheap.domains.dns.setHosts":
+		f.hosts = nil
+		for i := 1; r.Form.Has("HostName" + strconv.Itoa(i)); i++ {
+			suffix := strconv.Itoa(i)
+			ttl, _ := strconv.ParseInt(r.Form.Get("TTL"+suffix), 10, 64)
+			f.hosts = append(f.hosts, fakeNamecheapHost{
+				Name:    r.Form.Get("HostName" + suffix),
+				Type:    r.Form.Get("RecordType" + suffix),
+				Address: r.Form.Get("Address" + suffix),
+				TTL:     cmp.Or(ttl, 1800),
+			})
+		}
+		fmt.Fprint(w, `<ApiResponse Status="OK"><Errors/></ApiResponse>`)
+	default:
+		http.Error(w, "unknown command", http.StatusBadRequest)
+	}
+}
+
+func TestNamecheapDnsClient(t *testing.T) {
+	api := &fakeNamecheap{allowedIP: "203.0.113.10", hosts: []fakeNamecheapHost{
+		{Name: "www", Type: "CNAME", Address: "example.com.", TTL: 1800},
+		{Name: "lb", Type: "A", Address: "1.2.3.4", TTL: 300},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	t.Setenv("SENTINEL_NAMECHEAP_API_USER", "user")
+	t.Setenv("SENTINEL_NAMECHEAP_API_KEY", "key")
+	t.Setenv("SENTINEL_NAMECHEAP_CLIENT_IP", "203.0.113.10")
+	client, err := configureNamecheap(&Config{})
+	if err != nil {
+		t.Fatalf("configureNamecheap returned error: %v", err)
+	}
+	client.APIEndpoint = server.URL
+
+	ctx := context.Background()
+	record := libdns.Address{Name: "lb", IP: netip.MustParseAddr("5.6.7.8"), TTL: 300 * time.Second}
+
+	// Setting a record keeps all other hosts of the domain
+	if _, err := client.SetRecords(ctx, "example.com.", []libdns.Record{record}); err != nil {
+		t.Fatalf("SetRecords returned error: %v", err)
+	}
+	records, err := client.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Fatalf("GetRecords returned error: %v", err)
+	}
+	if len(records) != 2 || records[1].RR().Data != "5.6.7.8" {
+		t.Fatalf("expected www and lb -> 5.6.7.8, got %v", records)
+	}
+
+	// The stored TTL does not have to match
+	record.TTL = 0
+	deleted, err := client.DeleteRecords(ctx, "example.com.", []libdns.Record{record})
+	if err != nil {
+		t.Fatalf("DeleteRecords returned error: %v", err)
+	}
+	if len(deleted) != 1 || len(api.hosts) != 1 {
+		t.Errorf("expected lb to be deleted, got hosts %v", api.hosts)
+	}
+
+	for _, zone := range []string{"example.org.", "example.net."} {
+		if _, err := client.GetRecords(ctx, zone); !errors.Is(err, ErrZoneNotFound) {
+			t.Errorf("%s: expected a missing zone error, got %v", zone, err)
+		}
+	}
+
+	// Requests from an IP that is not whitelisted explain the whitelist
+	client.ClientIP = "198.51.100.1"
+	_, err = client.GetRecords(ctx, "example.com.")
+	if err == nil || !strings.Contains(err.Error(), "whitelist 198.51.100.1") {
+		t.Errorf("expected an error explaining the IP whitelist, got %v", err)
+	}
+	if code, category := providerErrorCode(DnsProviderNamecheap, err); code != namecheapErrInvalidIP || category != ProviderErrorAuth {
+		t.Errorf("expected the invalid IP code, got %q %q", code, category)
+	}
+}
+
+// fakeVultr is a Vultr API serving a single domain from memory, one record per page
+type fakeVultr struct {
+	records []govultr.DomainRecord
+	nextID  int
+}
+
+func (f *fakeVultr) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"Invalid API token.","status":401}`)
+		return
+	}
+	id, ok := strings.CutPrefix(r.URL.Path, "/v2/domains/example.com/records")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"Domain not found","status":404}`)
+		return
+	}
+	id = strings.TrimPrefix(id, "/")
+	index := slices.IndexFunc(f.records, func(record govultr.DomainRecord) bool { return record.ID == id })
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		var page struct {
+			Records []govultr.DomainRecord `json:"records"`
+			Meta    govultr.Meta           `json:"meta"`
+		}
+		page.Meta.Links = &govultr.Links{}
+		cursor := 0
+		fmt.Sscan(r.URL.Query().Get("cursor"), &cursor)
+		if cursor < len(f.records) {
+			page.Records = append(page.Records, f.records[cursor])
+		}
+		if cursor+1 < len(f.records) {
+			page.Meta.Links.Next = strconv.Itoa(cursor + 1)
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	case r.Method == http.MethodPost && id == "":
+		var record govultr.DomainRecord
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.nextID++
+		record.ID = strconv.Itoa(f.nextID)
+		f.records = append(f.records, record)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]govultr.DomainRecord{"record": record})
+	case index < 0:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"Record not found","status":404}`)
+	case r.Method == http.MethodPatch:
+		var update govultr.DomainRecord
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.records[index].Name, f.records[index].Data, f.records[index].TTL = update.Name, update.Data, update.TTL
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		f.records = slices.Delete(f.records, index, index+1)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestVultrDnsClient(t *testing.T) {
+	api := &fakeVultr{records: []govultr.DomainRecord{
+		{ID: "ns", Type: "NS", Name: "", Data: "ns1.vultr.com", TTL: 300},
+		{ID: "www", Type: "CNAME", Name: "www", Data: "example.com", TTL: 300},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewVultrDnsClient("secret")
+	client.client.SetBaseURL(server.URL)
+	record := func(name, ip string) libdns.Record {
+		return libdns.Address{Name: name, IP: netip.MustParseAddr(ip), TTL: 60 * time.Second}
+	}
+
+	if _, err := client.AppendRecords(ctx, "example.com.", []libdns.Record{record("@", "1.2.3.4"), record("@", "5.6.7.8")}); err != nil {
+		t.Fatalf("AppendRecords returned error: %v", err)
+	}
+
+	// The first record is updated in place, the other one removed
+	if _, err := client.SetRecords(ctx, "example.com.", []libdns.Record{record("@", "9.9.9.9")}); err != nil {
+		t.Fatalf("SetRecords returned error: %v", err)
+	}
+	if len(api.records) != 3 || api.records[2].ID != "1" || api.records[2].Data != "9.9.9.9" || api.records[2].TTL != 60 {
+		t.Errorf("expected record 1 to be updated to 9.9.9.9, got %v", api.records)
+	}
+
+	// All pages are read, the apex is named "@"
+	records, err := client.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Fatalf("GetRecords returned error: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %v", records)
+	}
+	if rr := records[2].RR(); rr.Name != "@" || rr.Type != "A" || rr.Data != "9.9.9.9" || rr.TTL != time.Minute {
+		t.Errorf("expected @ A 9.9.9.9, got %v", rr)
+	}
+
+	if _, err := client.DeleteRecords(ctx, "example.com.", []libdns.Record{record("@", "9.9.9.9")}); err != nil {
+		t.Fatalf("DeleteRecords returned error: %v", err)
+	}
+	if len(api.records) != 2 {
+		t.Errorf("expected the A record to be deleted, got %v", api.records)
+	}
+
+	if _, err := client.GetRecords(ctx, "example.org."); !errors.Is(err, ErrZoneNotFound) {
+		t.Errorf("expected ErrZoneNotFound for an unknown domain, got %v", err)
+	}
+	client = NewVultrDnsClient("wrong")
+	client.client.SetBaseURL(server.URL)
+	if _, err := client.GetRecords(ctx, "example.com."); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("expected 401 Unauthorized for a wrong API key, got %v", err)
+	}
+}
+
+// fakeRoute53Set is a resource record set of the Route 53 XML API
+type fakeRoute53Set struct {
+	Name            string
+	Type            string
+	SetIdentifier   string `xml:",omitempty"`
+	Weight          *int64 `xml:",omitempty"`
+	Failover        string `xml:",omitempty"`
+	TTL             int64
+	ResourceRecords []fakeRoute53Value `xml:"ResourceRecords>ResourceRecord"`
+	HealthCheckId   string             `xml:",omitempty"`
+}
+
+// fakeRoute53Value is a value of a resource record set
+type fakeRoute53Value struct {
+	Value string
+}
+
+// fakeRoute53 serves the hosted zone example.com. of the Route 53 API
+type fakeRoute53 struct {
+	sets []fakeRoute53Set
+}
+
+func (f *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/xml")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/hostedzonesbyname"):
+		fmt.Fprint(w, `<ListHostedZonesByNameResponse><HostedZones><HostedZone><Id>/hostedzone/Z1</Id><Name>example.com.</Name>`+
+			`<CallerReference>test</CallerReference><Config><PrivateZone>false</PrivateZone></Config></HostedZone></HostedZones>`+
+			`<IsTruncated>false</IsTruncated><MaxItems>100</MaxItems></ListHostedZonesByNameResponse>`)
+	case strings.HasSuffix(r.URL.Path, "/hostedzone/Z1/rrset") && r.Method == http.MethodGet:
+		xml.NewEncoder(w).Encode(struct {
+			XMLName     xml.Name         `xml:"ListResourceRecordSetsResponse"`
+			Sets        []fakeRoute53Set `xml:"ResourceRecordSets>ResourceRecordSet"`
+			IsTruncated bool
+			MaxItems    int
+		}{Sets: f.sets, MaxItems: 300})
+	case strings.HasSuffix(r.URL.Path, "/hostedzone/Z1/rrset") || strings.HasSuffix(r.URL.Path, "/hostedzone/Z1/rrset/"):
+		var request struct {
+			Changes []struct {
+				Action string
+				Set    fakeRoute53Set `xml:"ResourceRecordSet"`
+			} `xml:"ChangeBatch>Changes>Change"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, change := range request.Changes {
+			f.sets = slices.DeleteFunc(f.sets, func(s fakeRoute53Set) bool {
+				return s.Name == change.Set.Name && s.Type == change.Set.Type && s.SetIdentifier == change.Set.SetIdentifier
+			})
+			if change.Action != "DELETE" {
+				f.sets = append(f.sets, change.Set)
+			}
+		}
+		fmt.Fprint(w, `<ChangeResourceRecordSetsResponse><ChangeInfo><Id>/change/C1</Id><Status>INSYNC</Status>`+
+			`<SubmittedAt>2025-01-01T00:00:00Z</SubmittedAt></ChangeInfo></ChangeResourceRecordSetsResponse>`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// set returns the record set of the fake with name and setID
+func (f *fakeRoute53) set(name, setID string) *fakeRoute53Set {
+	for i, s := range f.sets {
+		if s.Name == name && s.SetIdentifier == setID {
+			return &f.sets[i]
+		}
+	}
+	return nil
+}
+
+func TestRoute53DnsClient(t *testing.T) {
+	weight := int64(10)
+	api := &fakeRoute53{sets: []fakeRoute53Set{
+		{Name: "example.com.", Type: "NS", TTL: 172800, ResourceRecords: []fakeRoute53Value{{"ns-1.awsdns-01.org."}}},
+		{Name: "lb.example.com.", Type: "A", SetIdentifier: "node-2", Weight: &weight, TTL: 60, ResourceRecords: []fakeRoute53Value{{"9.9.9.9"}}},
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("SENTINEL_ROUTE53_ACCESS_KEY_ID", "key")
+	t.Setenv("SENTINEL_ROUTE53_SECRET_ACCESS_KEY", "secret")
+
+	config := &Config{RecordOptions: RecordOptions{Weight: 20, SetIdentifier: "node-1", HealthCheckID: "hc-1"}}
+	client, err := configureRoute53(config)
+	if err != nil {
+		t.Fatalf("configureRoute53 returned error: %v", err)
+	}
+
+	ctx := context.Background()
+	record := func(ip string) libdns.Record {
+		return client.DecorateRecord(libdns.Address{Name: "lb", IP: netip.MustParseAddr(ip), TTL: 60 * time.Second}, config.RecordOptions)
+	}
+
+	// The record set of node-2 is not ours
+	records, err := client.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Fatalf("GetRecords returned error: %v", err)
+	}
+	if len(records) != 1 || records[0].RR().Type != "NS" {
+		t.Errorf("expected only the NS record, got %v", records)
+	}
+
+	if _, err := client.AppendRecords(ctx, "example.com.", []libdns.Record{record("1.2.3.4")}); err != nil {
+		t.Fatalf("AppendRecords returned error: %v", err)
+	}
+	if _, err := client.AppendRecords(ctx, "example.com.", []libdns.Record{record("5.6.7.8")}); err != nil {
+		t.Fatalf("AppendRecords returned error: %v", err)
+	}
+	set := api.set("lb.example.com.", "node-1")
+	if set == nil || set.Weight == nil || *set.Weight != 20 || set.HealthCheckId != "hc-1" || set.TTL != 60 ||
+		!slices.Equal(set.ResourceRecords, []fakeRoute53Value{{"1.2.3.4"}, {"5.6.7.8"}}) {
+		t.Fatalf("expected the weighted set node-1 with both addresses, got %+v", set)
+	}
+
+	records, err = client.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Fatalf("GetRecords returned error: %v", err)
+	}
+	if len(records) != 3 || records[1].RR().Data != "1.2.3.4" || records[2].RR().Data != "5.6.7.8" {
+		t.Errorf("expected the NS record and the addresses of node-1, got %v", records)
+	}
+
+	if _, err := client.SetRecords(ctx, "example.com.", []libdns.Record{record("4.4.4.4")}); err != nil {
+		t.Fatalf("SetRecords returned error: %v", err)
+	}
+	if set := api.set("lb.example.com.", "node-1"); set == nil || !slices.Equal(set.ResourceRecords, []fakeRoute53Value{{"4.4.4.4"}}) {
+		t.Errorf("expected node-1 to point to 4.4.4.4, got %+v", set)
+	}
+
+	// Plain records are written without routing policy
+	owner := libdns.TXT{Name: "_sentinel.lb", Text: "owner=node-1", TTL: 60 * time.Second}
+	if _, err := client.SetRecords(ctx, "example.com.", []libdns.Record{owner}); err != nil {
+		t.Fatalf("SetRecords returned error: %v", err)
+	}
+	if set := api.set("_sentinel.lb.example.com.", ""); set == nil || set.Type != "TXT" {
+		t.Errorf("expected a plain TXT record set, got %+v", set)
+	}
+
+	lb := libdns.Address{Name: "lb", IP: netip.MustParseAddr("4.4.4.4")}
+	if _, err := client.DeleteRecords(ctx, "example.com.", []libdns.Record{lb, owner}); err != nil {
+		t.Fatalf("DeleteRecords returned error: %v", err)
+	}
+	if api.set("lb.example.com.", "node-1") != nil || api.set("lb.example.com.", "node-2") == nil || api.set("_sentinel.lb.example.com.", "") != nil {
+		t.Errorf("expected only the set of node-1 and the TXT record to be deleted, got %+v", api.sets)
+	}
+
+	if _, err := client.GetRecords(ctx, "example.org."); !errors.Is(err, ErrZoneNotFound) {
+		t.Errorf("expected ErrZoneNotFound for an unknown zone, got %v", err)
+	}
+}
+
+func TestRoute53RecordSet(t *testing.T) {
+	rr := libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: time.Minute}
+
+	set := route53RecordSet("lb.example.com.", rr, RecordOptions{Failover: "PRIMARY", SetIdentifier: "primary"})
+	if set.Failover != "PRIMARY" || set.Weight != nil || set.HealthCheckId != nil || *set.SetIdentifier != "primary" {
+		t.Errorf("expected a failover set without weight, got %+v", set)
+	}
+
+	set = route53RecordSet("lb.example.com.", rr, RecordOptions{SetIdentifier: "node-1"})
+	if set.Failover != "" || set.Weight == nil || *set.Weight != 0 || *set.TTL != 60 {
+		t.Errorf("expected a weighted set with weight 0, got %+v", set)
+	}
+}
+
+func TestApplyProviderTTL(t *testing.T) {
+	tests := []struct {
+		provider   string
+		ttl        int64
+		defaultTTL int64
+		want       int64
+	}{
+		{DnsProviderInwx, 0, 300, 300},
+		{DnsProviderInwx, 60, 300, 300},
+		{DnsProviderInwx, 600, 300, 600},
+		{DnsProviderDesec, 300, desecMinTTL, desecMinTTL},
+		{DnsProviderGcloud, 5, 300, 5},
+	}
+
+	for _, tt := range tests {
+		config := &Config{RecordTTL: tt.ttl, RecordTTLs: map[string]int64{"www": 1}}
+		applyProviderTTL(config, tt.provider, tt.defaultTTL)
+		if config.RecordTTL != tt.want {
+			t.Errorf("%s with TTL %d: got %d, want %d", tt.provider, tt.ttl, config.RecordTTL, tt.want)
+		}
+		if want := max(1, providerMinTTLs[tt.provider]); config.RecordTTLs["www"] != want {
+			t.Errorf("%s: record TTL is %d, want %d", tt.provider, config.RecordTTLs["www"], want)
+		}
+	}
+
+	// 0 is valid and leaves the TTL to the provider
+	config, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig returned error: %v", err)
+	}
+	config.RecordTTL = 0
+	if err := config.Validate(); err != nil {
+		t.Errorf("expected TTL 0 to be valid, got %v", err)
+	}
+	t.Setenv("SENTINEL_RECORD_TTL", "-1")
+	config, err = NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig returned error: %v", err)
+	}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("expected an error for a negative TTL, got %v", err)
+	}
+	t.Setenv("SENTINEL_RECORD_TTL", "5m")
+	if _, err := NewConfig(); err == nil || !strings.Contains(err.Error(), "SENTINEL_RECORD_TTL") {
+		t.Errorf("expected an error for an invalid TTL, got %v", err)
+	}
+}
+
+func TestValidateRecordOptions(t *testing.T) {
+	tests := []struct {
+		options RecordOptions
+		want    string
+	}{
+		{RecordOptions{SetIdentifier: "node-1", Weight: 20}, ""},
+		{RecordOptions{SetIdentifier: "node-1", Failover: "PRIMARY", HealthCheckID: "hc-1"}, ""},
+		{RecordOptions{Weight: 20}, "require SENTINEL_RECORD_SET_ID"},
+		{RecordOptions{SetIdentifier: "node-1", Failover: "PRIMARY", Weight: 20}, "mutually exclusive"},
+		{RecordOptions{SetIdentifier: "node-1", Weight: 256}, "between 0 and 255"},
+		{RecordOptions{SetIdentifier: "node-1", Failover: "TERTIARY"}, "unsupported record failover"},
+	}
+
+	for _, tt := range tests {
+		config, err := NewConfig()
+		if err != nil {
+			t.Fatalf("NewConfig returned error: %v", err)
+		}
+		config.RecordOptions = tt.options
+		err = config.Validate()
+		if tt.want == "" && err != nil {
+			t.Errorf("%+v: expected no error, got %v", tt.options, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%+v: expected an error containing %q, got %v", tt.options, tt.want, err)
+		}
+	}
+}
+
+func TestProviderDefaultTTL(t *testing.T) {
+	for _, value := range []string{"0", "default"} {
+		t.Setenv("SENTINEL_RECORD_TTL", value)
+		config, err := NewConfig()
+		if err != nil {
+			t.Fatalf("NewConfig returned error: %v", err)
+		}
+		applyProviderTTL(config, DnsProviderInwx, 300)
+		if !config.ProviderTTL || config.RecordTTL != 0 {
+			t.Errorf("%s: got provider TTL %v and TTL %d, want true and 0", value, config.ProviderTTL, config.RecordTTL)
+		}
+	}
+
+	// The TTL the provider stored is left alone
+	dnsClient := &fakeDnsClient{records: []libdns.Record{
+		libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 3600 * time.Second},
+	}}
+	orchestration := &fakeOrchestration{leader: true, publicIP: "1.2.3.4"}
+	sentinel := newTestSentinel(dnsClient, orchestration)
+	sentinel.Config.RecordTTL = 0
+	sentinel.Config.ProviderTTL = true
+	ctx := context.Background()
+
+	if err := sentinel.CheckAndUpdateDNS(ctx); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+	if len(dnsClient.set)+len(dnsClient.appended) != 0 {
+		t.Errorf("expected no write for a differing TTL, got %v %v", dnsClient.set, dnsClient.appended)
+	}
+
+	// Changed records are written without TTL
+	orchestration.publicIP = "5.6.7.8"
+	if err := sentinel.CheckAndUpdateDNS(ctx); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+	written := append(dnsClient.set, dnsClient.appended...)
+	if len(written) != 1 || written[0].RR().Data != "5.6.7.8" || written[0].RR().TTL != 0 {
+		t.Errorf("expected lb A 5.6.7.8 without TTL, got %v", written)
+	}
+}
+
+func TestRecordsDrifted(t *testing.T) {
+	dnsClient := &fakeDnsClient{}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{leader: true, publicIP: "1.2.3.4"})
+	ctx := context.Background()
+
+	// Followers have nothing to check
+	if drifted, err := sentinel.recordsDrifted(ctx); err != nil || drifted {
+		t.Fatalf("expected no drift before the first check, got %v, %v", drifted, err)
+	}
+
+	if err := sentinel.CheckAndUpdateDNS(ctx); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+	dnsClient.records = dnsClient.appended
+	if drifted, err := sentinel.recordsDrifted(ctx); err != nil || drifted {
+		t.Fatalf("expected no drift after the update, got %v, %v", drifted, err)
+	}
+
+	// A manual change at the provider is detected without writing
+	dnsClient.records = []libdns.Record{libdns.RR{Name: "lb", Type: "A", Data: "9.9.9.9", TTL: 300 * time.Second}}
+	writes := len(dnsClient.set) + len(dnsClient.appended)
+	if drifted, err := sentinel.recordsDrifted(ctx); err != nil || !drifted {
+		t.Fatalf("expected drift after a manual change, got %v, %v", drifted, err)
+	}
+	if len(dnsClient.set)+len(dnsClient.appended) != writes {
+		t.Error("expected the check not to write")
+	}
+}
+
+func TestWithdrawOnShutdown(t *testing.T) {
+	existing := []libdns.Record{
+		libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+	}
+
+	tests := []struct {
+		name        string
+		leader      bool
+		fallbackIP  string
+		wantDeleted int
+		wantSet     string
+	}{
+		{"leader removes its address", true, "", 1, ""},
+		{"leader points to fallback", true, "9.9.9.9", 0, "9.9.9.9"},
+		{"fallback of other family", true, "2001:db8::1", 1, ""},
+		{"follower leaves records alone", false, "", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dnsClient := &fakeDnsClient{records: existing}
+			sentinel := newTestSentinel(dnsClient, &fakeOrchestration{leader: tt.leader, publicIP: "1.2.3.4"})
+			sentinel.Config.FallbackIP = tt.fallbackIP
+			sentinel.Config.AllowPrivateIP = true
+
+			if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+				t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+			}
+			if err := sentinel.WithdrawOnShutdown(context.Background()); err != nil {
+				t.Fatalf("WithdrawOnShutdown returned error: %v", err)
+			}
+
+			if len(dnsClient.deleted) != tt.wantDeleted {
+				t.Errorf("got %d deleted records, want %d", len(dnsClient.deleted), tt.wantDeleted)
+			}
+			var set string
+			if len(dnsClient.set) > 0 {
+				set = dnsClient.set[0].RR().Data
+			}
+			if set != tt.wantSet {
+				t.Errorf("got record set to %q, want %q", set, tt.wantSet)
+			}
+		})
+	}
+}
+
+func TestSyncReadyNodes(t *testing.T) {
+	transform, err := parseIPTransform("10.0.0.0/24=203.0.113.0/24")
+	if err != nil {
+		t.Fatalf("parseIPTransform returned error: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		family      string
+		wantAdded   []string
+		wantDeleted []string
+	}{
+		{"dual", IPFamilyDual, []string{"2001:db8::2"}, []string{"198.51.100.9", "2001:db8::9"}},
+		{"ipv4 only", IPFamilyIPv4, nil, []string{"198.51.100.9"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dnsClient := &fakeDnsClient{records: []libdns.Record{
+				libdns.RR{Name: "lb", Type: "A", Data: "203.0.113.1", TTL: 300 * time.Second},
+				libdns.RR{Name: "lb", Type: "A", Data: "203.0.113.2", TTL: 300 * time.Second},
+				libdns.RR{Name: "lb", Type: "A", Data: "198.51.100.9", TTL: 300 * time.Second},
+				libdns.RR{Name: "lb", Type: "AAAA", Data: "2001:db8::1", TTL: 300 * time.Second},
+				libdns.RR{Name: "lb", Type: "AAAA", Data: "2001:db8::9", TTL: 300 * time.Second},
+			}}
+			sentinel := newTestSentinel(dnsClient, &fakeOrchestration{readyIPs: []map[string]string{
+				{IPFamilyIPv4: "10.0.0.1", IPFamilyIPv6: "2001:db8::1"},
+				{IPFamilyIPv4: "10.0.0.2", IPFamilyIPv6: "2001:db8::2"},
+			}})
+			sentinel.Config.Mode = ModeMulti
+			sentinel.Config.IPFamily = tt.family
+			sentinel.Config.AllowPrivateIP = true
+			sentinel.ipTransform = transform
+
+			// Node addresses are transformed and filtered like the own ones
+			if err := sentinel.syncReadyNodes(context.Background(), sentinel.zones()[0], dnsClient.records); err != nil {
+				t.Fatalf("syncReadyNodes returned error: %v", err)
+			}
+
+			var added, deleted []string
+			for _, record := range dnsClient.appended {
+				added = append(added, record.RR().Data)
+			}
+			for _, record := range dnsClient.deleted {
+				deleted = append(deleted, record.RR().Data)
+			}
+			if !slices.Equal(added, tt.wantAdded) || !slices.Equal(deleted, tt.wantDeleted) {
+				t.Errorf("got added %v, deleted %v, want added %v, deleted %v", added, deleted, tt.wantAdded, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestWithdrawOnShutdownMultiMode(t *testing.T) {
+	dnsClient := &fakeDnsClient{}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{publicIP: "1.2.3.4", readyIPs: []map[string]string{{IPFamilyIPv4: "1.2.3.4"}}})
+	sentinel.Config.Mode = ModeMulti
+
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+	if err := sentinel.WithdrawOnShutdown(context.Background()); err != nil {
+		t.Fatalf("WithdrawOnShutdown returned error: %v", err)
+	}
+
+	if len(dnsClient.deleted) != 1 || dnsClient.deleted[0].RR().Data != "1.2.3.4" {
+		t.Errorf("got deleted records %v, want the address of this node", dnsClient.deleted)
+	}
+}
+
+func TestLeaderStampsHeartbeat(t *testing.T) {
+	dnsClient := &fakeDnsClient{records: []libdns.Record{
+		libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+	}}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{leader: true, publicIP: "1.2.3.4"})
+	sentinel.Config.FallbackAfter = 10 * time.Minute
+
+	for range 2 {
+		if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+			t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+		}
+	}
+
+	// The second check is within a quarter of FallbackAfter and writes no new heartbeat
+	if len(dnsClient.set) != 1 {
+		t.Fatalf("got %d records set, want 1 heartbeat", len(dnsClient.set))
+	}
+	leader, _, ok := parseHeartbeat(dnsClient.set, "example.com.", "_sentinel-heartbeat.lb")
+	if !ok || leader != sentinel.nodeName {
+		t.Errorf("got heartbeat %v, want one from %q", dnsClient.set[0].RR(), sentinel.nodeName)
+	}
+}
+
+func TestCoordinatorFallback(t *testing.T) {
+	heartbeat := func(age time.Duration) libdns.Record {
+		return libdns.TXT{
+			Name: "_sentinel-heartbeat.lb",
+			Text: fmt.Sprintf("heritage=sentinel,sentinel/leader=node-1,sentinel/heartbeat=%d", time.Now().Add(-age).Unix()),
+		}
+	}
+
+	tests := []struct {
+		name         string
+		heartbeat    []libdns.Record
+		wantFallback bool
+	}{
+		{"fresh heartbeat", []libdns.Record{heartbeat(time.Minute)}, false},
+		{"stale heartbeat", []libdns.Record{heartbeat(time.Hour)}, true},
+		{"no heartbeat", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dnsClient := &fakeDnsClient{records: append([]libdns.Record{
+				libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+				libdns.RR{Name: "lb", Type: "AAAA", Data: "2001:db8::1", TTL: 300 * time.Second},
+			}, tt.heartbeat...)}
+			sentinel := newTestSentinel(dnsClient, nil)
+			sentinel.Config.Mode = ModeCoordinator
+			sentinel.Config.FallbackIP = "9.9.9.9"
+			sentinel.Config.FallbackAfter = 15 * time.Minute
+
+			if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+				t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+			}
+
+			fellBack := len(dnsClient.set) == 1 && dnsClient.set[0].RR().Data == "9.9.9.9"
+			if fellBack != tt.wantFallback {
+				t.Errorf("got records set %v, want fallback %v", dnsClient.set, tt.wantFallback)
+			}
+			if tt.wantFallback && (len(dnsClient.deleted) != 1 || dnsClient.deleted[0].RR().Type != "AAAA") {
+				t.Errorf("got deleted records %v, want the AAAA record", dnsClient.deleted)
+			}
+		})
+	}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	dnsClient := &fakeDnsClient{records: []libdns.Record{
+		libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+	}}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{leader: true, publicIP: "5.6.7.8"})
+	sentinel.Config.DnsProvider = DnsProviderNoop
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	root, ok := spans["CheckAndUpdateDNS"]
+	if !ok {
+		t.Fatalf("no CheckAndUpdateDNS span in %v", slices.Collect(maps.Keys(spans)))
+	}
+	for _, name := range []string{"IsLeader", "GetRecords", "SetRecords"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if span.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s span is not a child of the reconcile span", name)
+		}
+	}
+
+	attrs := make(map[string]string)
+	for _, attr := range spans["SetRecords"].Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	want := map[string]string{"dns.zone": "example.com.", "dns.provider": DnsProviderNoop, "dns.record": "lb"}
+	if fmt.Sprint(attrs) != fmt.Sprint(want) {
+		t.Errorf("SetRecords attributes = %v, want %v", attrs, want)
+	}
+}
+
+func TestForceLeader(t *testing.T) {
+	tests := []struct {
+		force       string
+		leader      bool
+		wantUpdated bool
+	}{
+		{ForceLeaderAuto, true, true},
+		{ForceLeaderAuto, false, false},
+		{ForceLeaderTrue, false, true},
+		{ForceLeaderFalse, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/leader=%v", tt.force, tt.leader), func(t *testing.T) {
+			dnsClient := &fakeDnsClient{records: []libdns.Record{
+				libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+			}}
+			sentinel := newTestSentinel(dnsClient, &fakeOrchestration{leader: tt.leader, publicIP: "5.6.7.8"})
+			sentinel.Config.ForceLeader = tt.force
+
+			if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+				t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+			}
+			if updated := len(dnsClient.set) > 0; updated != tt.wantUpdated {
+				t.Errorf("updated = %v, want %v", updated, tt.wantUpdated)
+			}
+		})
+	}
+
+	config := &Config{ForceLeader: "yes"}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), `force leader value "yes"`) {
+		t.Errorf("expected an error for an invalid force leader value, got %v", err)
+	}
+}
+
+func TestReconcileLagTrigger(t *testing.T) {
+	sentinel := newTestSentinel(&fakeDnsClient{}, &fakeOrchestration{})
+
+	start := time.Now()
+	if got := sentinel.takeTrigger(start); !got.Equal(start) {
+		t.Errorf("takeTrigger without event = %v, want the reconcile start", got)
+	}
+
+	// A burst of events reports the lag of its first one
+	sentinel.markTriggered()
+	first := sentinel.triggeredAt
+	time.Sleep(time.Millisecond)
+	sentinel.markTriggered()
+	if got := sentinel.takeTrigger(time.Now()); !got.Equal(first) {
+		t.Errorf("takeTrigger = %v, want the first event at %v", got, first)
+	}
+	if !sentinel.triggeredAt.IsZero() {
+		t.Error("trigger not cleared by the reconcile covering it")
+	}
+
+	sentinel.markTriggered()
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+	if !sentinel.triggeredAt.IsZero() {
+		t.Error("trigger not cleared by CheckAndUpdateDNS")
+	}
+}
+
+func TestProviderErrorCode(t *testing.T) {
+	tests := []struct {
+		provider     string
+		err          error
+		wantCode     string
+		wantCategory string
+	}{
+		{DnsProviderInwx, errors.New("(2200) Authentication error"), "2200", ProviderErrorAuth},
+		{DnsProviderInwx, errors.New("(2306) Parameter value policy error. Reason: (1) TTL too low"), "2306", ProviderErrorInvalidRecord},
+		{DnsProviderBunny, errors.New("Too Many Requests (429)"), "429", ProviderErrorRateLimit},
+		{DnsProviderGcloud, errors.New("googleapi: Error 403: Request had insufficient authentication scopes., forbidden"), "403", ProviderErrorAuth},
+		{DnsProviderNamecheap, errors.New("(1011150) Invalid request IP"), "1011150", ProviderErrorAuth},
+		{DnsProviderDesec, fmt.Errorf("%w: example.com", ErrZoneNotFound), "", ProviderErrorNotFound},
+		{DnsProviderBunny, errors.New("connection reset"), "", ""},
+	}
+
+	for _, tt := range tests {
+		code, category := providerErrorCode(tt.provider, tt.err)
+		if code != tt.wantCode || category != tt.wantCategory {
+			t.Errorf("providerErrorCode(%s, %q) = %q, %q, want %q, %q", tt.provider, tt.err, code, category, tt.wantCode, tt.wantCategory)
+		}
+	}
+
+	sentinel := newTestSentinel(&fakeDnsClient{}, &fakeOrchestration{})
+	sentinel.Config.DnsProvider = DnsProviderInwx
+	err := sentinel.reconcileError("update", "example.com.", "lb", errors.New("(2200) Authentication error"))
+	if want := "update of lb in zone example.com. via inwx failed: (2200) Authentication error [provider code 2200, auth]"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	var buf bytes.Buffer
+	dnsClient := &fakeDnsClient{records: []libdns.Record{
+		libdns.RR{Name: "lb", Type: "A", Data: "1.2.3.4", TTL: 300 * time.Second},
+	}}
+	sentinel := newTestSentinel(dnsClient, &fakeOrchestration{leader: true, publicIP: "5.6.7.8"})
+	sentinel.events = &eventLog{w: &buf}
+
+	if err := sentinel.CheckAndUpdateDNS(context.Background()); err != nil {
+		t.Fatalf("CheckAndUpdateDNS returned error: %v", err)
+	}
+
+	var events []Event
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			t.Fatalf("invalid event line %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+
+	want := []Event{
+		{Event: EventLeaderAcquired},
+		{Event: EventDNSUpdated, Record: "lb.example.com.", Old: "1.2.3.4", New: "5.6.7.8", Result: EventResultSucceeded},
+		{Event: EventReconciled, Result: EventResultSucceeded},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %s", len(events), len(want), buf.String())
+	}
+	for i, event := range events {
+		if event.Timestamp.IsZero() {
+			t.Errorf("event %d has no timestamp", i)
+		}
+		event.Timestamp = time.Time{}
+		if event != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, event, want[i])
+		}
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sentinel.yaml")
+	err := os.WriteFile(path, []byte(`
+domain: example.com
+record: [lb, www]
+record_ttl: 300
+record_ttls:
+  www: 3600
+reconcile_interval: 1m
+dry_run: true
+dns_provider: inwx
+inwx:
+  user: alice
+extra_zones:
+  - domain: example.org
+    provider: bunny
+    records: [api]
+  - domain: example.net
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SENTINEL_CONFIG_FILE", path)
+	t.Setenv("SENTINEL_RECORD_TTL", "600")
+	t.Cleanup(func() { fileConfig = nil })
+
+	config, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig returned error: %v", err)
+	}
+
+	if !slices.Equal(config.Records, []string{"lb", "www"}) {
+		t.Errorf("got records %v, want [lb www]", config.Records)
+	}
+	if config.RecordTTL != 600 {
+		t.Errorf("got TTL %d, want 600 from the environment", config.RecordTTL)
+	}
+	if config.RecordTTLs["www"] != 3600 {
+		t.Errorf("got TTL overrides %v, want www: 3600", config.RecordTTLs)
+	}
+	if config.ReconcileInterval != time.Minute || !config.DryRun {
+		t.Errorf("got reconcile interval %s and dry run %v, want 1m and true", config.ReconcileInterval, config.DryRun)
+	}
+	if user := getEnv("INWX_USER", ""); user != "alice" {
+		t.Errorf("got INWX user %q, want alice", user)
+	}
+
+	want := []ZoneRecords{
+		{Domain: "example.org", Zone: "example.org", Records: []string{"api"}, DnsProvider: "bunny"},
+		{Domain: "example.net", Zone: "example.net", Records: []string{"lb", "www"}},
+	}
+	if len(config.ExtraZones) != len(want) {
+		t.Fatalf("got extra zones %+v, want %+v", config.ExtraZones, want)
+	}
+	for i, z := range config.ExtraZones {
+		if z.Domain != want[i].Domain || z.DnsProvider != want[i].DnsProvider || !slices.Equal(z.Records, want[i].Records) {
+			t.Errorf("extra zone %d = %+v, want %+v", i, z, want[i])
+		}
+	}
+}
+
+// fakeZoneLister is a fakeDnsClient that can list its zones
+type fakeZoneLister struct {
+	fakeDnsClient
+	zones []string
+}
+
+func (f *fakeZoneLister) ListZones(context.Context) ([]libdns.Zone, error) {
+	var zones []libdns.Zone
+	for _, name := range f.zones {
+		zones = append(zones, libdns.Zone{Name: name})
+	}
+	return zones, nil
+}
+
+// missingZoneClient is a fakeDnsClient whose provider knows no zones
+type missingZoneClient struct {
+	fakeDnsClient
+}
+
+func (f *missingZoneClient) GetRecords(context.Context, string) ([]libdns.Record, error) {
+	return nil, fmt.Errorf("(2303) Object does not exist: %w", ErrZoneNotFound)
+}
+
+func TestCheckZones(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  DnsClient
+		wantErr bool
+	}{
+		{"records readable", &fakeDnsClient{}, false},
+		{"zone listed", &fakeZoneLister{zones: []string{"example.org.", "EXAMPLE.com."}}, false},
+		{"zone not listed", &fakeZoneLister{zones: []string{"example.org."}}, true},
+		{"provider reports missing zone", &missingZoneClient{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sentinel := newTestSentinel(tt.client, &fakeOrchestration{})
+			sentinel.Config.DnsProvider = DnsProviderInwx
+
+			err := sentinel.checkZones(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "zone example.com does not exist at DNS provider inwx") {
+				t.Errorf("got error %q, want it to name the zone and provider", err)
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	sentinel := newTestSentinel(&fakeDnsClient{}, &fakeOrchestration{})
+	interval := 5 * time.Minute
+
+	if got := sentinel.jitter(interval); got != interval {
+		t.Errorf("got %s without jitter, want %s", got, interval)
+	}
+
+	sentinel.Config.TimerJitter = 10
+	for range 100 {
+		if got := sentinel.jitter(interval); got < 270*time.Second || got > 330*time.Second {
+			t.Fatalf("got %s, want within 10%% of %s", got, interval)
+		}
+	}
+}
+
+// fakeNameserver answers every A query over UDP with addr, authoritative if
+// authoritative is set, and returns its port
+func fakeNameserver(t *testing.T, addr netip.Addr, authoritative bool) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1232)
+		for {
+			n, client, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var parser dnsmessage.Parser
+			header, err := parser.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			question, err := parser.Question()
+			if err != nil {
+				continue
+			}
+			if header.RecursionDesired {
+				t.Errorf("query for %s asks for recursion", question.Name)
+			}
+
+			builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, Authoritative: authoritative})
+			_ = builder.StartQuestions()
+			_ = builder.Question(question)
+			_ = builder.StartAnswers()
+			_ = builder.AResource(dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 300}, dnsmessage.AResource{A: addr.As4()})
+			response, _ := builder.Finish()
+			_, _ = conn.WriteTo(response, client)
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	return port
+}
+
+func TestQueryAuthoritative(t *testing.T) {
+	defer func(port string) { nameserverPort = port }(nameserverPort)
+
+	nameserverPort = fakeNameserver(t, netip.MustParseAddr("1.2.3.4"), true)
+	answers, err := queryAuthoritative(context.Background(), "127.0.0.1", "A", "lb.example.com")
+	if err != nil {
+		t.Fatalf("queryAuthoritative returned error: %v", err)
+	}
+	if !slices.Equal(answers, []string{"1.2.3.4"}) {
+		t.Errorf("got answers %v, want [1.2.3.4]", answers)
+	}
+
+	nameserverPort = fakeNameserver(t, netip.MustParseAddr("1.2.3.4"), false)
+	if _, err := queryAuthoritative(context.Background(), "127.0.0.1", "A", "lb.example.com"); err == nil {
+		t.Error("got no error for a non-authoritative answer")
+	}
+}
+
+func TestBuildDnsClientUnknownProvider(t *testing.T) {
+	_, err := buildDnsClient("route66", &Config{})
+	if err == nil {
+		t.Fatal("expected an error for an unknown provider")
+	}
+	if want := `unsupported DNS provider "route66" (supported: inwx, bunny`; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got error %q, want it to start with %q", err, want)
+	}
+}
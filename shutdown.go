@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/libdns/libdns"
+)
+
+// WithdrawOnShutdown removes the addresses of this node from DNS when it shuts
+// down, so clients are not sent to a node that is going away until the next
+// leader takes over. With a fallback IP the records are pointed to it instead.
+// In multi mode every node withdraws its own addresses, otherwise only the leader.
+func (s *Sentinel) WithdrawOnShutdown(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Config.Mode == ModeMulti {
+		return s.withdrawOwnRecords(ctx, "Shutting down")
+	}
+	if !s.wasLeader {
+		logger.Debugf("Not the leader, leaving DNS records to the leader")
+		return nil
+	}
+
+	logger.Infof("Shutting down as the leader, withdrawing the records of this node")
+	var errs []error
+	for _, z := range s.zones() {
+		if err := s.withdrawZone(ctx, z); err != nil {
+			errs = append(errs, fmt.Errorf("zone %s: %w", z.zone(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// withdrawZone removes the records of this node from a single zone, or points
+// those of the fallback IP's family to it
+func (s *Sentinel) withdrawZone(ctx context.Context, z ZoneRecords) error {
+	zone := z.zone()
+
+	var fallback, own []libdns.Record
+	for _, name := range z.recordNames() {
+		for _, record := range s.desiredRecords(name, s.recordTTL(z, name)) {
+			if replacement, ok := s.fallbackRecord(record); ok {
+				fallback = append(fallback, replacement)
+			} else {
+				own = append(own, record)
+			}
+		}
+	}
+
+	if len(fallback) > 0 {
+		records, err := s.getRecords(ctx, zone, false)
+		if err != nil {
+			reconcileErr := s.reconcileError("get records", zone, "", err)
+			logger.Errorf("Could not get DNS records: %v", reconcileErr)
+			return reconcileErr
+		}
+
+		failed := 0
+		for _, desired := range fallback {
+			if s.updateRecord(ctx, zone, records, desired) == recordFailed {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d records could not be pointed to the fallback IP", failed, len(fallback))
+		}
+	}
+
+	return s.deleteRecords(ctx, zone, own)
+}
+
+// fallbackRecord returns the record pointing to the fallback IP that replaces
+// record, if a fallback IP of the same family is configured
+func (s *Sentinel) fallbackRecord(record libdns.Record) (libdns.Record, bool) {
+	address, ok := record.(libdns.Address)
+	if !ok || s.Config.FallbackIP == "" {
+		return nil, false
+	}
+
+	ip, err := netip.ParseAddr(s.Config.FallbackIP)
+	if err != nil || ip.Unmap().Is4() != address.IP.Is4() {
+		return nil, false
+	}
+	address.IP = ip.Unmap()
+	return address, true
+}
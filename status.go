@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// Status is the state of this instance as seen by the last DNS check
+type Status struct {
+	Node          string         `json:"node"`
+	Leader        bool           `json:"leader"`
+	ForcedLeader  bool           `json:"forced_leader,omitempty"`
+	PublicIP      string         `json:"public_ip,omitempty"`
+	PublicIPv6    string         `json:"public_ipv6,omitempty"`
+	CNAMETarget   string         `json:"cname_target,omitempty"`
+	Zones         []string       `json:"zones"`
+	Records       []string       `json:"records"`
+	DNSRecords    []StatusRecord `json:"dns_records"`
+	LastReconcile *time.Time     `json:"last_reconcile,omitempty"`
+	LastError     string         `json:"last_error,omitempty"`
+}
+
+// StatusRecord is a managed record as last read from the DNS provider
+type StatusRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+	TTL  int64  `json:"ttl"`
+}
+
+// recordStatus stores the outcome of a DNS check for the status endpoint
+func (s *Sentinel) recordStatus(isLeader bool, err error) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	now := time.Now()
+	s.status.Node = s.nodeName
+	s.status.Leader = isLeader
+	s.status.ForcedLeader = s.Config.ForceLeader == ForceLeaderTrue || s.Config.ForceLeader == ForceLeaderFalse
+	s.status.PublicIP = s.Config.ServerIP
+	s.status.PublicIPv6 = s.Config.ServerIPv6
+	s.status.CNAMETarget = s.Config.CNAMETarget
+	s.status.Zones, s.status.Records = s.managedNames()
+	s.status.LastReconcile = &now
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+}
+
+// managedNames returns the managed zones and the fully qualified names of all records
+func (s *Sentinel) managedNames() (zones, records []string) {
+	for _, z := range s.zones() {
+		zones = append(zones, z.zone())
+		records = append(records, z.fqdns()...)
+	}
+	return zones, records
+}
+
+// recordStatusRecords keeps the managed records of z out of all records read from its zone
+func (s *Sentinel) recordStatusRecords(z ZoneRecords, records []libdns.Record) {
+	zone := z.zone()
+	names := z.recordNames()
+	managed := []StatusRecord{}
+	for _, record := range records {
+		rr := record.RR()
+		if rr.Type != "A" && rr.Type != "AAAA" && rr.Type != RecordTypeCNAME {
+			continue
+		}
+		if !slices.ContainsFunc(names, func(name string) bool { return sameName(rr.Name, name, zone) }) {
+			continue
+		}
+		managed = append(managed, StatusRecord{
+			Name: strings.TrimSuffix(libdns.AbsoluteName(normalizeName(rr.Name, zone), zone), "."),
+			Type: rr.Type,
+			Data: rr.Data,
+			TTL:  int64(rr.TTL.Seconds()),
+		})
+	}
+
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	if s.statusRecords == nil {
+		s.statusRecords = make(map[string][]StatusRecord)
+	}
+	s.statusRecords[zone] = managed
+}
+
+// handleStatus serves the state of the last DNS check as JSON
+func (s *Sentinel) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	s.statusMu.Lock()
+	status := s.status
+	status.DNSRecords = []StatusRecord{}
+	for _, z := range s.zones() {
+		status.DNSRecords = append(status.DNSRecords, s.statusRecords[z.zone()]...)
+	}
+	s.statusMu.Unlock()
+
+	if status.Records == nil {
+		status.Zones, status.Records = s.managedNames()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		logger.Errorf("Error writing status: %v", err)
+	}
+}
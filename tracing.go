@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of reconciles, leadership checks and DNS provider
+// calls. It discards them until setupTracing installs an exporter.
+var tracer = otel.Tracer("sentinel")
+
+// setupTracing exports spans via OTLP/HTTP if OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set. The exporter reads the other
+// standard OTEL_* variables itself. The returned function flushes the
+// remaining spans on shutdown.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithHTTPClient(&http.Client{Transport: httpTransport, Timeout: 10 * time.Second}))
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP exporter: %v", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			attribute.String("service.name", "sentinel"),
+			attribute.String("service.version", version),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	logger.Infof("Exporting traces via OTLP")
+	return provider.Shutdown, nil
+}
+
+// startSpan starts a span called name as a child of the span in ctx
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan marks span as failed if err is set and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// reconcileSpan starts the span of a CheckAndUpdateDNS run
+func (s *Sentinel) reconcileSpan(ctx context.Context) (context.Context, trace.Span) {
+	return startSpan(ctx, "CheckAndUpdateDNS",
+		attribute.String("sentinel.node", s.nodeName),
+		attribute.String("sentinel.mode", s.Config.Mode),
+		attribute.String("dns.zone", s.zones()[0].zone()),
+		attribute.String("dns.provider", s.Config.DnsProvider),
+	)
+}
+
+// providerSpan starts the span of a DNS provider call for name in zone, name
+// is left out for calls covering the whole zone
+func (s *Sentinel) providerSpan(ctx context.Context, operation, zone, name string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("dns.zone", zone),
+		attribute.String("dns.provider", s.providerName(zone)),
+	}
+	if name != "" {
+		attrs = append(attrs, attribute.String("dns.record", name))
+	}
+	return startSpan(ctx, operation, attrs...)
+}
+
+// providerName returns the DNS provider managing zone
+func (s *Sentinel) providerName(zone string) string {
+	for _, z := range s.zones() {
+		if z.zone() == zone && z.DnsProvider != "" {
+			return z.DnsProvider
+		}
+	}
+	return s.Config.DnsProvider
+}
+
+// checkLeader asks the orchestrator whether this node leads, in a span.
+// SENTINEL_FORCE_LEADER takes precedence over the orchestrator.
+func (s *Sentinel) checkLeader(ctx context.Context) (bool, error) {
+	_, span := startSpan(ctx, "IsLeader",
+		attribute.String("sentinel.orchestration", s.Config.OrchestrationType),
+		attribute.String("sentinel.force_leader", s.Config.ForceLeader),
+	)
+	var isLeader bool
+	var err error
+	switch s.Config.ForceLeader {
+	case ForceLeaderTrue:
+		isLeader = true
+	case ForceLeaderFalse:
+		isLeader = false
+	default:
+		isLeader, err = s.orchestration.IsLeader(ctx)
+	}
+	span.SetAttributes(attribute.Bool("sentinel.leader", isLeader))
+	endSpan(span, err)
+	return isLeader, err
+}
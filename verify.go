@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// verifyPollInterval is the delay between propagation checks
+const verifyPollInterval = 5 * time.Second
+
+// nameserverPort is the port authoritative nameservers are queried on
+var nameserverPort = "53"
+
+// nameserverResult is the answer of a single authoritative nameserver
+type nameserverResult struct {
+	Host    string
+	Answers []string
+	Err     error
+	Matches bool
+}
+
+// String describes the result for the log
+func (r nameserverResult) String() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("%s: %v", r.Host, r.Err)
+	case r.Matches:
+		return fmt.Sprintf("%s: up to date", r.Host)
+	case len(r.Answers) == 0:
+		return fmt.Sprintf("%s: no answer", r.Host)
+	default:
+		return fmt.Sprintf("%s: answers %s", r.Host, strings.Join(r.Answers, ", "))
+	}
+}
+
+// verifyPropagation queries every authoritative nameserver of zone for fqdn
+// until all of them answer want or VerifyTimeout passes. The nameservers are
+// asked directly and without recursion, so caches cannot hide a change the
+// provider did not apply. It only logs and records the outcome, the DNS update
+// itself already succeeded at the provider.
+func (s *Sentinel) verifyPropagation(ctx context.Context, zone, rrType, fqdn, want string) {
+	ctx, cancel := context.WithTimeout(ctx, s.Config.VerifyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	for {
+		results, err := queryNameservers(ctx, zone, rrType, fqdn, want)
+		if err != nil {
+			logger.Debugf("Could not look up nameservers of %s: %v", zone, err)
+		} else if len(results) > 0 && !slices.ContainsFunc(results, func(r nameserverResult) bool { return !r.Matches }) {
+			logger.Infof("Propagation of %s %s -> %s confirmed by %d nameservers after %s", rrType, fqdn, want, len(results), time.Since(start).Round(time.Second))
+			for _, result := range results {
+				logger.Infof("Nameserver %s", result)
+			}
+			dnsPropagationTotal.WithLabelValues("confirmed").Inc()
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Warnf("Propagation of %s %s -> %s not confirmed within %s", rrType, fqdn, want, s.Config.VerifyTimeout)
+			for _, result := range results {
+				logger.Warnf("Nameserver %s", result)
+			}
+			dnsPropagationTotal.WithLabelValues("timeout").Inc()
+			return
+		case <-time.After(verifyPollInterval):
+			for _, result := range results {
+				logger.Debugf("Nameserver %s", result)
+			}
+		}
+	}
+}
+
+// queryNameservers asks each authoritative nameserver of zone for the records
+// of type rrType called fqdn and compares their answers with want
+func queryNameservers(ctx context.Context, zone, rrType, fqdn, want string) ([]nameserverResult, error) {
+	nameservers, err := net.DefaultResolver.LookupNS(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []nameserverResult
+	for _, ns := range nameservers {
+		result := nameserverResult{Host: strings.TrimSuffix(ns.Host, ".")}
+		result.Answers, result.Err = queryAuthoritative(ctx, ns.Host, rrType, fqdn)
+		result.Matches = result.Err == nil && slices.ContainsFunc(result.Answers, func(answer string) bool {
+			return sameContent(rrType, answer, want)
+		})
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// queryAuthoritative sends a non-recursive query with EDNS0 for fqdn to the
+// nameserver host, trying each of its addresses, and returns the data of the
+// answers of type rrType. A nonexistent name has no answers.
+func queryAuthoritative(ctx context.Context, host, rrType, fqdn string) ([]string, error) {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", strings.TrimSuffix(host, "."))
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		answers, err := exchange(ctx, net.JoinHostPort(addr.Unmap().String(), nameserverPort), rrType, fqdn)
+		if err == nil {
+			return answers, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// exchange queries the nameserver at addr over UDP, falling back to TCP for
+// truncated responses
+func exchange(ctx context.Context, addr, rrType, fqdn string) ([]string, error) {
+	qtype := dnsmessage.TypeA
+	switch rrType {
+	case "AAAA":
+		qtype = dnsmessage.TypeAAAA
+	case RecordTypeCNAME:
+		qtype = dnsmessage.TypeCNAME
+	}
+
+	name, err := dnsmessage.NewName(strings.TrimSuffix(fqdn, ".") + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %q: %v", fqdn, err)
+	}
+
+	id := uint16(rand.Uint32())
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id})
+	builder.EnableCompression()
+	_ = builder.StartQuestions()
+	_ = builder.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET})
+	_ = builder.StartAdditionals()
+	var opt dnsmessage.ResourceHeader
+	_ = opt.SetEDNS0(1232, dnsmessage.RCodeSuccess, false)
+	_ = builder.OPTResource(opt, dnsmessage.OPTResource{})
+	query, err := builder.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("error building query: %v", err)
+	}
+
+	response, err := roundTrip(ctx, "udp", addr, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response from %s: %v", addr, err)
+	}
+	if header.Truncated {
+		if response, err = roundTrip(ctx, "tcp", addr, query); err != nil {
+			return nil, err
+		}
+		if header, err = parser.Start(response); err != nil {
+			return nil, fmt.Errorf("invalid response from %s: %v", addr, err)
+		}
+	}
+
+	if header.ID != id {
+		return nil, fmt.Errorf("response from %s does not match the query", addr)
+	}
+	if header.RCode == dnsmessage.RCodeNameError {
+		return nil, nil
+	}
+	if header.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("%s answered %s", addr, header.RCode)
+	}
+	if !header.Authoritative {
+		return nil, fmt.Errorf("%s is not authoritative for %s", addr, fqdn)
+	}
+
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, fmt.Errorf("invalid response from %s: %v", addr, err)
+	}
+
+	var answers []string
+	for {
+		answer, err := parser.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid response from %s: %v", addr, err)
+		}
+		if answer.Type != qtype || !strings.EqualFold(answer.Name.String(), name.String()) {
+			if err := parser.SkipAnswer(); err != nil {
+				return nil, fmt.Errorf("invalid response from %s: %v", addr, err)
+			}
+			continue
+		}
+
+		switch qtype {
+		case dnsmessage.TypeA:
+			r, err := parser.AResource()
+			if err != nil {
+				return nil, err
+			}
+			answers = append(answers, netip.AddrFrom4(r.A).String())
+		case dnsmessage.TypeAAAA:
+			r, err := parser.AAAAResource()
+			if err != nil {
+				return nil, err
+			}
+			answers = append(answers, netip.AddrFrom16(r.AAAA).Unmap().String())
+		case dnsmessage.TypeCNAME:
+			r, err := parser.CNAMEResource()
+			if err != nil {
+				return nil, err
+			}
+			answers = append(answers, r.CNAME.String())
+		}
+	}
+	return answers, nil
+}
+
+// roundTrip sends query to addr and returns the response. TCP messages are
+// prefixed with their length.
+func roundTrip(ctx context.Context, network, addr string, query []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > 5*time.Second {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	if network == "tcp" {
+		query = append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	if network == "tcp" {
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		response := make([]byte, binary.BigEndian.Uint16(length[:]))
+		_, err := io.ReadFull(conn, response)
+		return response, err
+	}
+
+	response := make([]byte, 1232)
+	n, err := conn.Read(response)
+	if err != nil {
+		return nil, err
+	}
+	return response[:n], nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, injected via -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo is the build information printed by --version
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// buildInfo returns the build information, falling back to the VCS data
+// embedded by the Go toolchain if no commit was injected
+func buildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if embedded, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range embedded.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+
+	return info
+}
+
+// printVersion writes the build information as a single JSON line to stdout
+func printVersion() {
+	// BuildInfo only holds strings, so encoding cannot fail
+	_ = json.NewEncoder(os.Stdout).Encode(buildInfo())
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/vultr/govultr/v3"
+	"golang.org/x/oauth2"
+)
+
+// VultrDnsClient manages records through the Vultr DNS API. libdns/vultr is
+// not compatible with libdns v1, so the govultr client it builds on is used
+// directly.
+type VultrDnsClient struct {
+	client *govultr.Client
+}
+
+// NewVultrDnsClient creates a Vultr client authenticating with apiKey
+func NewVultrDnsClient(apiKey string) *VultrDnsClient {
+	httpClient := &http.Client{
+		Transport: &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: apiKey}),
+			Base:   httpTransport,
+		},
+		Timeout: 30 * time.Second,
+	}
+	return &VultrDnsClient{client: govultr.NewClient(httpClient)}
+}
+
+// vultrAPIError is the body govultr reports as error for failed requests
+type vultrAPIError struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// explain turns the raw response body govultr reports for failed requests
+// into an error naming the status, wrapping ErrZoneNotFound for unknown domains
+func (c *VultrDnsClient) explain(zone string, err error) error {
+	var apiErr vultrAPIError
+	if err == nil || json.Unmarshal([]byte(err.Error()), &apiErr) != nil || apiErr.Status == 0 {
+		return err
+	}
+
+	if apiErr.Status == http.StatusNotFound {
+		return fmt.Errorf("domain %s not found in the Vultr account: %s: %w", vultrDomain(zone), apiErr.Error, ErrZoneNotFound)
+	}
+	return fmt.Errorf("unexpected status from Vultr API: %d %s: %s", apiErr.Status, http.StatusText(apiErr.Status), apiErr.Error)
+}
+
+// listRecords returns all records of zone, following pagination
+func (c *VultrDnsClient) listRecords(ctx context.Context, zone string) ([]govultr.DomainRecord, error) {
+	var records []govultr.DomainRecord
+
+	options := &govultr.ListOptions{PerPage: 500}
+	for {
+		page, meta, _, err := c.client.DomainRecord.List(ctx, vultrDomain(zone), options)
+		if err != nil {
+			return nil, c.explain(zone, err)
+		}
+		records = append(records, page...)
+
+		if meta == nil || meta.Links == nil || meta.Links.Next == "" {
+			return records, nil
+		}
+		options.Cursor = meta.Links.Next
+	}
+}
+
+// GetRecords lists all records in zone
+func (c *VultrDnsClient) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	existing, err := c.listRecords(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]libdns.Record, 0, len(existing))
+	for _, record := range existing {
+		records = append(records, libdns.RR{
+			Name: cmp.Or(record.Name, "@"),
+			Type: record.Type,
+			Data: record.Data,
+			TTL:  time.Duration(record.TTL) * time.Second,
+		})
+	}
+	return records, nil
+}
+
+// AppendRecords adds records to zone, skipping records that already exist
+func (c *VultrDnsClient) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	existing, err := c.listRecords(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []libdns.Record
+	for _, record := range records {
+		want := vultrRecordOf(zone, record)
+		exists := false
+		for _, current := range existing {
+			if current.Type == want.Type && strings.EqualFold(current.Name, want.Name) && sameContent(want.Type, current.Data, want.Data) {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+
+		if err := c.create(ctx, zone, want); err != nil {
+			return created, err
+		}
+		created = append(created, record)
+	}
+	return created, nil
+}
+
+// SetRecords replaces the records of zone sharing name and type with records.
+// Existing records are updated in place, so the name never stops resolving.
+func (c *VultrDnsClient) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	existing, err := c.listRecords(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	for _, record := range records {
+		want := vultrRecordOf(zone, record)
+
+		var reuse *govultr.DomainRecord
+		for i, current := range existing {
+			if current.Type == want.Type && strings.EqualFold(current.Name, want.Name) && !used[current.ID] {
+				reuse = &existing[i]
+				break
+			}
+		}
+
+		if reuse == nil {
+			if err := c.create(ctx, zone, want); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		used[reuse.ID] = true
+		update := &govultr.DomainRecordUpdateReq{Name: &want.Name, Data: want.Data, TTL: want.TTL}
+		if err := c.client.DomainRecord.Update(ctx, vultrDomain(zone), reuse.ID, update); err != nil {
+			return nil, fmt.Errorf("error updating %s record %s: %w", want.Type, cmp.Or(want.Name, "@"), c.explain(zone, err))
+		}
+	}
+
+	// Further records of the replaced sets are left over
+	for _, current := range existing {
+		if used[current.ID] {
+			continue
+		}
+		for _, record := range records {
+			want := vultrRecordOf(zone, record)
+			if current.Type == want.Type && strings.EqualFold(current.Name, want.Name) {
+				if err := c.client.DomainRecord.Delete(ctx, vultrDomain(zone), current.ID); err != nil {
+					return nil, fmt.Errorf("error deleting %s record %s: %w", current.Type, cmp.Or(current.Name, "@"), c.explain(zone, err))
+				}
+				break
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// DeleteRecords removes records from zone. Records without data remove all
+// records with their name and type.
+func (c *VultrDnsClient) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	existing, err := c.listRecords(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []libdns.Record
+	for _, current := range existing {
+		for _, record := range records {
+			want := vultrRecordOf(zone, record)
+			if current.Type != want.Type || !strings.EqualFold(current.Name, want.Name) {
+				continue
+			}
+			if want.Data != "" && !sameContent(want.Type, current.Data, want.Data) {
+				continue
+			}
+
+			if err := c.client.DomainRecord.Delete(ctx, vultrDomain(zone), current.ID); err != nil {
+				return deleted, fmt.Errorf("error deleting %s record %s: %w", current.Type, cmp.Or(current.Name, "@"), c.explain(zone, err))
+			}
+			deleted = append(deleted, record)
+			break
+		}
+	}
+	return deleted, nil
+}
+
+// create adds record to zone
+func (c *VultrDnsClient) create(ctx context.Context, zone string, record govultr.DomainRecord) error {
+	_, _, err := c.client.DomainRecord.Create(ctx, vultrDomain(zone), &govultr.DomainRecordCreateReq{
+		Name: record.Name,
+		Type: record.Type,
+		Data: record.Data,
+		TTL:  record.TTL,
+	})
+	return c.explain(zone, err)
+}
+
+// vultrDomain returns the Vultr domain serving zone
+func vultrDomain(zone string) string {
+	return strings.TrimSuffix(zone, ".")
+}
+
+// vultrRecordOf converts record into a Vultr record with a name relative to zone
+func vultrRecordOf(zone string, record libdns.Record) govultr.DomainRecord {
+	rr := record.RR()
+
+	name := libdns.RelativeName(libdns.AbsoluteName(rr.Name, zone), zone)
+	if name == "@" {
+		name = ""
+	}
+
+	// Vultr stores host names without the trailing dot
+	data := rr.Data
+	if rr.Type == RecordTypeCNAME {
+		data = strings.TrimSuffix(data, ".")
+	}
+
+	return govultr.DomainRecord{
+		Type: rr.Type,
+		Name: name,
+		Data: data,
+		TTL:  int(rr.TTL.Seconds()),
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const WebhookEventLeaderAcquired = "leader_acquired"
+const WebhookEventDNSUpdated = "dns_updated"
+
+// WebhookEvent is the JSON payload posted to SENTINEL_WEBHOOK_URL
+type WebhookEvent struct {
+	Event     string    `json:"event"`
+	Node      string    `json:"node"`
+	Record    string    `json:"record,omitempty"`
+	OldIP     string    `json:"old_ip,omitempty"`
+	NewIP     string    `json:"new_ip,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// webhookClient is used for all webhook deliveries
+var webhookClient = &http.Client{Transport: httpTransport, Timeout: 10 * time.Second}
+
+// notifyWebhook posts event to the configured webhook in the background.
+// Delivery failures are only logged and never affect the DNS reconcile.
+func (s *Sentinel) notifyWebhook(event WebhookEvent) {
+	if s.Config.WebhookURL == "" {
+		return
+	}
+
+	event.Node = s.nodeName
+	event.Timestamp = time.Now().UTC()
+
+	go func() {
+		if err := postWebhook(s.Config.WebhookURL, event); err != nil {
+			logger.Errorf("Error delivering %s webhook: %v", event.Event, err)
+		}
+	}()
+}
+
+// postWebhook sends event as JSON to url
+func postWebhook(url string, event WebhookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding payload: %v", err)
+	}
+
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// ErrZoneNotFound is wrapped by DNS clients whose provider reported that a zone does not exist
+var ErrZoneNotFound = errors.New("zone not found")
+
+// checkZones makes sure every managed zone exists at its DNS provider, so a
+// mistyped SENTINEL_DOMAIN or SENTINEL_ZONE fails at startup instead of on the
+// first update. Other errors are only logged, the provider may just be
+// unreachable right now and the reconcile reports it again later.
+func (s *Sentinel) checkZones(ctx context.Context) error {
+	var errs []error
+	for _, z := range s.zones() {
+		zone := z.zone()
+		provider := z.DnsProvider
+		if provider == "" {
+			provider = s.Config.DnsProvider
+		}
+
+		err := s.attempt(ctx, func(ctx context.Context) error {
+			return zoneExists(ctx, s.dnsClient(zone), zone)
+		})
+		if errors.Is(err, ErrZoneNotFound) {
+			errs = append(errs, fmt.Errorf("zone %s does not exist at DNS provider %s, check SENTINEL_DOMAIN, SENTINEL_ZONE and SENTINEL_EXTRA_ZONES: %v",
+				strings.TrimSuffix(zone, "."), provider, err))
+		} else if err != nil {
+			logger.Warnf("Could not check that zone %s exists at DNS provider %s: %v", zone, provider, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// zoneExists asks client whether zone exists. Providers that can list their
+// zones are looked up in that list, the others are asked for the records of
+// the zone, which fails with ErrZoneNotFound for a missing zone.
+func zoneExists(ctx context.Context, client DnsClient, zone string) error {
+	if lister, ok := client.(libdns.ZoneLister); ok {
+		zones, err := lister.ListZones(ctx)
+		if err == nil {
+			for _, z := range zones {
+				if strings.EqualFold(strings.TrimSuffix(z.Name, "."), strings.TrimSuffix(zone, ".")) {
+					return nil
+				}
+			}
+			return ErrZoneNotFound
+		}
+		logger.Debugf("Could not list zones, reading the records of %s instead: %v", zone, err)
+	}
+
+	_, err := client.GetRecords(ctx, zone)
+	return err
+}
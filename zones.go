@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// ZoneRecords is a set of records below Domain, managed through the DNS provider in Zone
+type ZoneRecords struct {
+	Domain      string
+	Zone        string // defaults to Domain
+	Records     []string
+	DnsProvider string // defaults to the primary DNS provider
+}
+
+// parseExtraZones parses SENTINEL_EXTRA_ZONES, a semicolon separated list of
+// "domain[:provider]=record,record" entries. Entries without records manage the
+// same records as the primary domain, "domain=" manages the apex.
+func parseExtraZones(value string, defaultRecords []string) []ZoneRecords {
+	var zones []ZoneRecords
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		domain, rawRecords, hasRecords := strings.Cut(entry, "=")
+		records := defaultRecords
+		if hasRecords {
+			records = splitList(rawRecords)
+			if len(records) == 0 {
+				records = []string{"@"}
+			}
+		}
+
+		domain, provider, _ := strings.Cut(strings.TrimSpace(domain), ":")
+		zones = append(zones, ZoneRecords{Domain: domain, Zone: domain, Records: records, DnsProvider: provider})
+	}
+	return zones
+}
+
+// zones returns the primary zone followed by the extra zones
+func (s *Sentinel) zones() []ZoneRecords {
+	primary := ZoneRecords{
+		Domain:  s.Config.Domain,
+		Zone:    s.Config.Zone,
+		Records: s.Config.Records,
+	}
+	return append([]ZoneRecords{primary}, s.Config.ExtraZones...)
+}
+
+// zone returns the fully qualified zone the records are managed in
+func (z ZoneRecords) zone() string {
+	zone := z.Zone
+	if zone == "" {
+		zone = z.Domain
+	}
+	return strings.TrimSuffix(zone, ".") + "."
+}
+
+// recordNames returns the configured records, which are relative to Domain,
+// as names relative to the zone, e.g. "api" in domain internal.example.com
+// becomes "api.internal" in zone example.com
+func (z ZoneRecords) recordNames() []string {
+	domain := strings.TrimSuffix(z.Domain, ".") + "."
+
+	var names []string
+	for _, name := range z.Records {
+		// The apex is always named "@", which providers translate to their own notation
+		if name == "" {
+			name = "@"
+		}
+		names = append(names, libdns.RelativeName(libdns.AbsoluteName(name, domain), z.zone()))
+	}
+	return names
+}
+
+// fqdns returns the fully qualified names of the records, without trailing dot
+func (z ZoneRecords) fqdns() []string {
+	var fqdns []string
+	for _, name := range z.recordNames() {
+		fqdns = append(fqdns, strings.TrimSuffix(libdns.AbsoluteName(name, z.zone()), "."))
+	}
+	return fqdns
+}